@@ -0,0 +1,268 @@
+//Package batchlimiter 预先获取配额的批量限制器
+//包装任意满足limiterhelper.LimiterInterface的限制器,每次从redis中预先获取一批配额保存在本地,之后的注水直接消耗本地的配额
+//本地配额用完后才会再次访问redis,因此高并发时可以大幅减少访问redis的次数
+//本地没有用完的配额会在LeaseTTL后或调用Close时归还,被包装的限制器需要实现limiterhelper.RefunderInterface(如incrlimiter,gcralimiter)才能归还,否则只会丢弃
+//被包装的限制器实现了limiterhelper.TryTakerInterface(如incrlimiter)时使用TryTake获取配额,获取失败不会占用容量
+//被包装的限制器实现了limiterhelper.TakerInterface时,本地配额最多保留到被包装的限制器容量恢复(比如固定窗口结束),之后直接丢弃不再归还
+//精度和吞吐量的取舍由BatchSize和LeaseTTL控制,每个实例最多多占用BatchSize的配额,接近容量时其他实例可能提前被限流
+//访问redis时不持有本地锁,并发的注水在本地配额用完时可能同时获取新的一批配额
+package batchlimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+)
+
+//waterlineChecker 内置了limiterhelper.LimiterABC的限制器,用于在使用Take获取配额时触发钩子
+type waterlineChecker interface {
+	CheckWaterline(size int64, blocked bool) bool
+}
+
+//Limiter 预先获取配额的批量限制器
+type Limiter struct {
+	opt     Options
+	limiter limiterhelper.LimiterInterface
+	lock    sync.Mutex
+	tokens  int64
+	resetAt time.Time //被包装的限制器容量恢复的时间,到达后本地配额直接丢弃
+	timer   *time.Timer
+	gen     uint64 //本地配额的批次,用于忽略已经失效的定时器
+	closed  bool
+}
+
+//New 创建一个批量限制器
+//@params limiter limiterhelper.LimiterInterface 被包装的限制器
+//@params opts ...optparams.Option[Options] 批量限制器的配置
+func New(limiter limiterhelper.LimiterInterface, opts ...optparams.Option[Options]) (*Limiter, error) {
+	c := new(Limiter)
+	c.opt = defaultOptions
+	optparams.GetOption(&c.opt, opts...)
+	if c.opt.LeaseTTL <= 0 {
+		return nil, ErrLeaseTTLMustLargerThanZero
+	}
+	c.limiter = limiter
+	return c, nil
+}
+
+//lease 从被包装的限制器获取配额
+//@returns bool 是否获取成功
+//@returns time.Time 被包装的限制器容量恢复的时间,无法获取时为零值
+func (c *Limiter) lease(ctx context.Context, value int64) (bool, time.Time, error) {
+	var res *limiterhelper.Reservation
+	var err error
+	if trytaker, ok := c.limiter.(limiterhelper.TryTakerInterface); ok {
+		res, err = trytaker.TryTake(ctx, value)
+	} else if taker, ok := c.limiter.(limiterhelper.TakerInterface); ok {
+		res, err = taker.Take(ctx, value)
+	} else {
+		ok, err := c.limiter.Flood(ctx, value)
+		return ok, time.Time{}, err
+	}
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if checker, ok := c.limiter.(waterlineChecker); ok {
+		checker.CheckWaterline(res.WaterLevel(), !res.Allowed)
+	}
+	return res.Allowed, res.ResetAt, nil
+}
+
+//Flood 灌注
+//本地配额足够时直接消耗本地配额,否则从被包装的限制器获取一批配额,整批获取失败时再尝试只获取需要的量
+//当返回为true说明注水成功,false表示无法注入(满了)
+func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
+	if value <= 0 {
+		return c.limiter.Flood(ctx, value)
+	}
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return c.limiter.Flood(ctx, value)
+	}
+	if c.tokens >= value {
+		c.tokens -= value
+		c.lock.Unlock()
+		return true, nil
+	}
+	c.lock.Unlock()
+	request := value
+	if request < c.opt.BatchSize {
+		request = c.opt.BatchSize
+	}
+	ok, resetAt, err := c.lease(ctx, request)
+	if err != nil {
+		return true, err
+	}
+	if !ok && request > value {
+		request = value
+		ok, resetAt, err = c.lease(ctx, request)
+		if err != nil {
+			return true, err
+		}
+	}
+	if !ok {
+		return false, nil
+	}
+	extra := request - value
+	if extra <= 0 {
+		return true, nil
+	}
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		//获取配额期间已经关闭,多出的配额直接归还
+		if refunder, ok := c.limiter.(limiterhelper.RefunderInterface); ok {
+			err := refunder.Refund(ctx, extra)
+			if err != nil {
+				log.Warn("batch limiter refund get error", log.Dict{"err": err.Error()})
+			}
+		}
+		return true, nil
+	}
+	//之前的配额已经过了被包装的限制器容量恢复的时间,直接丢弃
+	if !c.resetAt.IsZero() && !time.Now().Before(c.resetAt) {
+		c.tokens = 0
+	}
+	c.tokens += extra
+	c.schedule(resetAt)
+	c.lock.Unlock()
+	return true, nil
+}
+
+//schedule 为新获取的一批配额重新设置归还本地配额的定时器,调用方需要持有锁
+func (c *Limiter) schedule(resetAt time.Time) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.gen++
+	wait := c.opt.LeaseTTL
+	if !resetAt.IsZero() {
+		if until := time.Until(resetAt); until < wait {
+			wait = until
+		}
+	}
+	c.resetAt = resetAt
+	gen := c.gen
+	c.timer = time.AfterFunc(wait, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.gen != gen {
+			return
+		}
+		err := c.flush(context.Background())
+		if err != nil {
+			log.Warn("batch limiter refund get error", log.Dict{"err": err.Error()})
+		}
+	})
+}
+
+//flush 归还本地没有用完的配额,被包装的限制器容量已经恢复时直接丢弃
+func (c *Limiter) flush(ctx context.Context) error {
+	tokens := c.tokens
+	resetAt := c.resetAt
+	c.tokens = 0
+	c.resetAt = time.Time{}
+	c.gen++
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if tokens <= 0 {
+		return nil
+	}
+	if !resetAt.IsZero() && !time.Now().Before(resetAt) {
+		return nil
+	}
+	refunder, ok := c.limiter.(limiterhelper.RefunderInterface)
+	if !ok {
+		return nil
+	}
+	return refunder.Refund(ctx, tokens)
+}
+
+//Flush 立即归还本地没有用完的配额
+func (c *Limiter) Flush(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.flush(ctx)
+}
+
+//Close 归还本地没有用完的配额,之后的注水不再预先获取配额而是直接访问被包装的限制器
+func (c *Limiter) Close(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	return c.flush(ctx)
+}
+
+//Tokens 本地剩余的配额
+func (c *Limiter) Tokens() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tokens
+}
+
+//WaterLevel 当前水位,即被包装的限制器的水位减去本地剩余的配额
+func (c *Limiter) WaterLevel(ctx context.Context) (int64, error) {
+	level, err := c.limiter.WaterLevel(ctx)
+	if err != nil {
+		return 0, err
+	}
+	level -= c.Tokens()
+	if level < 0 {
+		level = 0
+	}
+	return level, nil
+}
+
+//Capacity 容量
+func (c *Limiter) Capacity() int64 {
+	return c.limiter.Capacity()
+}
+
+//IsFull 观测水位是否已满,本地有剩余配额时不算满
+func (c *Limiter) IsFull(ctx context.Context) (bool, error) {
+	if c.Tokens() > 0 {
+		return false, nil
+	}
+	return c.limiter.IsFull(ctx)
+}
+
+//Reset 丢弃本地剩余的配额并重置被包装的限制器
+func (c *Limiter) Reset(ctx context.Context) error {
+	c.lock.Lock()
+	c.tokens = 0
+	c.resetAt = time.Time{}
+	c.gen++
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.lock.Unlock()
+	return c.limiter.Reset(ctx)
+}
+
+//OnWarning 注册在到警戒水位时的钩子,钩子会在从被包装的限制器获取配额时触发
+func (c *Limiter) OnWarning(fn limiterhelper.Hook) error {
+	return c.limiter.OnWarning(fn)
+}
+
+//OnFull 注册在到水位满时的钩子,钩子会在从被包装的限制器获取配额时触发
+func (c *Limiter) OnFull(fn limiterhelper.Hook) error {
+	return c.limiter.OnFull(fn)
+}
+
+//OnRecovered 注册水位回落到警戒水位以下时的钩子,钩子会在从被包装的限制器获取配额时触发
+//被包装的限制器没有实现limiterhelper.RecoveredHookInterface时返回limiterhelper.ErrRecoveredHookNotSupported
+func (c *Limiter) OnRecovered(fn limiterhelper.Hook) error {
+	l, ok := c.limiter.(limiterhelper.RecoveredHookInterface)
+	if !ok {
+		return limiterhelper.ErrRecoveredHookNotSupported
+	}
+	return l.OnRecovered(fn)
+}
@@ -0,0 +1,8 @@
+package batchlimiter
+
+import (
+	"errors"
+)
+
+//ErrLeaseTTLMustLargerThanZero 预先获取的注水量在本地保留的时长必须大于0
+var ErrLeaseTTLMustLargerThanZero = errors.New("lease ttl must larger than 0")
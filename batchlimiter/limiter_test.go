@@ -0,0 +1,226 @@
+package batchlimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/gcralimiter"
+	"github.com/Golang-Tools/redishelper/v2/incrlimiter"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+var _ limiterhelper.LimiterInterface = (*Limiter)(nil)
+var _ limiterhelper.RecoveredHookInterface = (*Limiter)(nil)
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+func Test_limiter_options(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	inner, err := incrlimiter.New(ck, incrlimiter.WithMaxTTL(time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	_, err = New(inner, WithLeaseTTL(0))
+	assert.Equal(t, ErrLeaseTTLMustLargerThanZero, err)
+}
+
+func Test_limiter_batch(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//每分钟只补充1个令牌,测试期间可以忽略补充
+	inner, err := gcralimiter.New(ck, gcralimiter.WithMaxSize(15), gcralimiter.WithWarningSize(10), gcralimiter.WithDefaultCountPerPeriod(1), gcralimiter.WithDefaultPeriod(60), gcralimiter.WithSpecifiedKey("test_batchlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "gcralimiter new get error")
+	}
+	limiter, err := New(inner, WithBatchSize(10), WithLeaseTTL(time.Minute))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	for i := 0; i < 4; i++ {
+		ok, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, ok)
+	}
+	assert.Equal(t, int64(6), limiter.Tokens())
+	innerlevel, err := inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(10), innerlevel)
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(4), wl)
+	//接近容量时整批获取失败,只获取需要的量
+	for i := 0; i < 11; i++ {
+		ok, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, ok, i)
+	}
+	ok, err := limiter.Flood(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, false, ok)
+	full, err := limiter.IsFull(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter IsFull get error")
+	}
+	assert.Equal(t, true, full)
+}
+
+func Test_limiter_close(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	inner, err := gcralimiter.New(ck, gcralimiter.WithMaxSize(100), gcralimiter.WithWarningSize(80), gcralimiter.WithDefaultCountPerPeriod(1), gcralimiter.WithDefaultPeriod(60), gcralimiter.WithSpecifiedKey("test_batchlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "gcralimiter new get error")
+	}
+	limiter, err := New(inner, WithBatchSize(20), WithLeaseTTL(time.Minute))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	for i := 0; i < 5; i++ {
+		limiter.Flood(ctx, 1)
+	}
+	err = limiter.Close(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Close get error")
+	}
+	assert.Equal(t, int64(0), limiter.Tokens())
+	innerlevel, err := inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(5), innerlevel)
+	//关闭后直接访问被包装的限制器
+	limiter.Flood(ctx, 1)
+	innerlevel, err = inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(6), innerlevel)
+}
+
+func Test_limiter_lease_expire(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	inner, err := incrlimiter.New(ck, incrlimiter.WithMaxTTL(10*time.Second), incrlimiter.WithMaxSize(100), incrlimiter.WithWarningSize(80), incrlimiter.WithSpecifiedKey("test_batchlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	limiter, err := New(inner, WithBatchSize(10), WithLeaseTTL(100*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	ok, err := limiter.Flood(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, ok)
+	innerlevel, err := inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(10), innerlevel)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int64(0), limiter.Tokens())
+	innerlevel, err = inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(1), innerlevel)
+	err = limiter.Reset(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Reset get error")
+	}
+	innerlevel, err = inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(0), innerlevel)
+}
+
+func Test_limiter_incr_lease(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	inner, err := incrlimiter.New(ck, incrlimiter.WithMaxTTL(10*time.Second), incrlimiter.WithMaxSize(15), incrlimiter.WithWarningSize(12), incrlimiter.WithSpecifiedKey("test_batchlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	limiter, err := New(inner, WithBatchSize(10), WithLeaseTTL(200*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	for i := 0; i < 10; i++ {
+		ok, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, ok)
+	}
+	//整批获取失败时不占用容量,之后只获取需要的量
+	time.Sleep(150 * time.Millisecond)
+	ok, err := limiter.Flood(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, ok)
+	innerlevel, err := inner.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "inner WaterLevel get error")
+	}
+	assert.Equal(t, int64(11), innerlevel)
+}
+
+func Test_limiter_reschedule(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	inner, err := incrlimiter.New(ck, incrlimiter.WithMaxTTL(10*time.Second), incrlimiter.WithMaxSize(100), incrlimiter.WithWarningSize(80), incrlimiter.WithSpecifiedKey("test_batchlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	limiter, err := New(inner, WithBatchSize(10), WithLeaseTTL(200*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	//每批配额都重新计算归还时间,不会沿用第一批的定时器
+	ok, err := limiter.Flood(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, ok)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		limiter.Flood(ctx, 1)
+	}
+	assert.Equal(t, int64(9), limiter.Tokens())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(9), limiter.Tokens())
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, int64(0), limiter.Tokens())
+}
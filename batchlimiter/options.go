@@ -0,0 +1,35 @@
+package batchlimiter
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+)
+
+type Options struct {
+	BatchSize int64         //每次从redis中预先获取的注水量
+	LeaseTTL  time.Duration //预先获取的注水量在本地保留的最长时间,超时后没有用完的部分会被归还
+}
+
+var defaultOptions = Options{
+	BatchSize: 10,
+	LeaseTTL:  time.Second,
+}
+
+//WithBatchSize 设置每次从redis中预先获取的注水量,默认10,必须大于0
+//越大访问redis的次数越少,但每个实例最多会多占用一批配额,接近容量时其他实例可能提前被限流
+func WithBatchSize(size int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if size > 0 {
+			o.BatchSize = size
+		}
+	})
+}
+
+//WithLeaseTTL 设置预先获取的注水量在本地保留的最长时间,默认1s
+//越短配额被闲置占用的时间越短,但归还和重新获取的次数越多,固定窗口的限制器应小于窗口时长
+func WithLeaseTTL(ttl time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.LeaseTTL = ttl
+	})
+}
@@ -7,6 +7,9 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/Golang-Tools/loggerhelper/v2"
 	"github.com/Golang-Tools/optparams"
@@ -25,10 +28,40 @@ type Cachefunc func() ([]byte, error)
 //缓存设置UpdatePeriod后会自动定时同步数据
 type Cache struct {
 	*middlewarehelper.MiddleWareAbc
-	opt        Options
-	latestHash string     //上次跟新后保存数据的hash,用于避免重复更新
-	updateFunc Cachefunc  //更新缓存的函数
-	c          *cron.Cron //定时任务对象
+	opt          Options
+	hashLock     sync.Mutex               //保护latestHash
+	latestHash   string                   //上次跟新后保存数据的hash,用于避免重复更新
+	updateFunc   Cachefunc                //更新缓存的函数
+	c            *cron.Cron               //定时任务对象
+	flightLock   sync.Mutex               //保护flight
+	flight       map[ForceLevelType]*call //按强制执行类型区分的正在执行的被动加载
+	lastDelta    int64                    //最近一次执行缓存函数的耗时(ns),用于提前过期的概率计算
+	revalidating int32                    //是否正在后台重新验证过期数据
+}
+
+//call 一次正在执行的被动加载,并发的缓存未命中会等待并共享它的结果
+type call struct {
+	done chan struct{}
+	res  []byte
+	err  error
+}
+
+//detachedContext 保留父ctx中的值但不会随父ctx取消或超时的ctx
+//共享的被动加载不能因为发起它的调用方取消而让其他等待的调用方一起失败
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
 }
 
 //New 创建一个缓存实例
@@ -42,6 +75,9 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Cache,
 		return nil, err
 	}
 	cache.MiddleWareAbc = m
+	if cache.opt.SoftTTL > 0 && cache.MaxTTL() > 0 && cache.opt.SoftTTL >= cache.MaxTTL() {
+		return nil, ErrSoftTTLMustLessThanMaxTTL
+	}
 	return cache, nil
 }
 
@@ -64,7 +100,7 @@ func (c *Cache) update(force ForceLevelType) ([]byte, error) {
 	var res []byte
 	var reserr error
 	if force == ForceLevel__NOCONSTRAINT {
-		res, reserr = c.updateFunc()
+		res, reserr = c.callUpdateFunc()
 	} else {
 		//限制器限制执行防止被击穿
 		if c.opt.Limiter != nil {
@@ -80,27 +116,173 @@ func (c *Cache) update(force ForceLevelType) ([]byte, error) {
 			if !canflood {
 				return nil, ErrLimiterNotAllow
 			}
-			res, reserr = c.updateFunc()
+			res, reserr = c.callUpdateFunc()
 		} else {
-			res, reserr = c.updateFunc()
+			res, reserr = c.callUpdateFunc()
 		}
 	}
 	return res, reserr
 }
 
+//callUpdateFunc 执行注册的缓存函数并记录耗时
+func (c *Cache) callUpdateFunc() ([]byte, error) {
+	start := time.Now()
+	res, err := c.updateFunc()
+	atomic.StoreInt64(&c.lastDelta, int64(time.Since(start)))
+	return res, err
+}
+
+//fencingSetScript 带fencing token写入缓存的脚本,token小于已写入的token时拒绝写入
+//KEYS[1]为缓存的key,KEYS[2]为记录写入token的key
+//ARGV[1]为缓存的值,ARGV[2]为fencing token,ARGV[3]为过期时间(ms)
+// 返回0表示token过期,拒绝写入
+// 返回1表示写入成功
+var fencingSetScript = redis.NewScript(`
+local latest = tonumber(redis.call("GET", KEYS[2]) or "0")
+if tonumber(ARGV[2]) < latest then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+	redis.call("SET", KEYS[2], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+	redis.call("SET", KEYS[2], ARGV[2])
+end
+return 1`)
+
+//FencingKey 记录最近一次写入缓存的fencing token使用的键,与缓存落在同一个集群slot
+func (c *Cache) FencingKey() string {
+	return c.SubKey("::fencing")
+}
+
+//fencingEnabled 设置的锁是否启用了fencing token
+func (c *Cache) fencingEnabled() bool {
+	if c.opt.Lock == nil {
+		return false
+	}
+	f, ok := c.opt.Lock.(lock.FencingTokenInterface)
+	return ok && f.FencingEnabled()
+}
+
+//lockToken 获取当前持有的锁对应的fencing token,未设置锁或锁不支持fencing token时为0
+//需要在获得锁后立即调用,之后锁可能被其他goroutine释放或重新获取
+func (c *Cache) lockToken() int64 {
+	if c.opt.Lock == nil {
+		return 0
+	}
+	if f, ok := c.opt.Lock.(lock.FencingTokenInterface); ok {
+		return f.Token()
+	}
+	return 0
+}
+
+//isNegative 结果是否需要作为空结果以NegativeTTL缓存
+func (c *Cache) isNegative(res []byte) bool {
+	return len(res) == 0 && c.opt.EmptyResCacheMode == EmptyResCacheMode__NEGATIVE
+}
+
+//set 将数据写入缓存
+//如果持有的锁提供了fencing token,则写入时附带token,token比已写入的token旧时拒绝写入并返回ErrStaleFencingToken
+//锁启用了fencing token但没有token(比如获取锁失败)时不写入并返回ErrFencingTokenUnavailable
+//负缓存模式下空结果使用NegativeTTL作为过期时间
+//@params token int64 获得锁时得到的fencing token,没有时为0
+func (c *Cache) set(ctx context.Context, res []byte, token int64) error {
+	ttl := c.MaxTTL()
+	if c.isNegative(res) {
+		ttl = c.opt.NegativeTTL
+	}
+	if c.staleEnabled() {
+		res = c.wrapStale(res)
+	}
+	if token <= 0 {
+		if c.fencingEnabled() {
+			return ErrFencingTokenUnavailable
+		}
+		_, err := c.Client().Set(ctx, c.Key(), res, ttl).Result()
+		if err != nil {
+			return err
+		}
+		c.afterSet(ctx, ttl)
+		return nil
+	}
+	r, err := fencingSetScript.Run(ctx, c.Client(), []string{c.Key(), c.FencingKey()}, res, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if r == 0 {
+		return ErrStaleFencingToken
+	}
+	c.afterSet(ctx, ttl)
+	return nil
+}
+
+//afterSet 写入缓存后通知近端缓存失效并记录标签
+func (c *Cache) afterSet(ctx context.Context, ttl time.Duration) {
+	c.invalidateNear(ctx)
+	err := addTags(ctx, c.Client(), &c.opt, taggedKey{key: c.Key(), ttl: ttl.Milliseconds(), tags: c.opt.Tags})
+	if err != nil {
+		c.Logger().Warn("add cache tags get error", map[string]any{"err": err.Error()})
+	}
+}
+
+//invalidateNear 通知近端缓存缓存已失效
+func (c *Cache) invalidateNear(ctx context.Context) {
+	if c.opt.Near == nil {
+		return
+	}
+	err := c.opt.Near.Invalidate(ctx, c.Key())
+	if err != nil {
+		c.Logger().Warn("invalidate near cache get error", map[string]any{"err": err.Error()})
+	}
+}
+
+//Delete 删除缓存,同时通知近端缓存失效
+//@params ctx context.Context 上下文信息,用于控制请求的结束
+func (c *Cache) Delete(ctx context.Context) error {
+	err := c.MiddleWareAbc.Delete(ctx)
+	c.invalidateNear(ctx)
+	return err
+}
+
+//getRaw 获取缓存中的原始数据,设置了近端缓存时优先从近端缓存获取
+//@returns bool 是否由近端缓存命中
+func (c *Cache) getRaw(ctx context.Context) (string, bool, error) {
+	if c.opt.Near != nil {
+		if res, ok := c.opt.Near.get(c.Key()); ok {
+			return res, true, nil
+		}
+	}
+	res, err := c.Client().Get(ctx, c.Key()).Result()
+	if err != nil {
+		return "", false, err
+	}
+	if c.opt.Near != nil {
+		c.opt.Near.set(c.Key(), res)
+	}
+	return res, false, nil
+}
+
 //saveToCache 将数据存至缓存并刷新过期时间
-func (c *Cache) saveToCache(ctx context.Context, res []byte) {
+//@params token int64 获得锁时得到的fencing token,没有时为0
+func (c *Cache) saveToCache(ctx context.Context, res []byte, token int64) {
 	h := md5.New()
 	h.Write(res)
 	resMd5 := hex.EncodeToString(h.Sum(nil))
 	//结果写入缓存
-	if c.latestHash == "" || c.latestHash != resMd5 {
-		_, err := c.Client().Set(ctx, c.Key(), res, c.MaxTTL()).Result()
+	//开启软过期时每次写入都需要刷新软过期时间
+	c.hashLock.Lock()
+	latestHash := c.latestHash
+	c.hashLock.Unlock()
+	if latestHash == "" || latestHash != resMd5 || c.staleEnabled() || c.isNegative(res) {
+		err := c.set(ctx, res, token)
 		if err != nil {
 			c.Logger().Debug("set cache get error", map[string]any{"err": err.Error()})
 		} else {
 			c.Logger().Debug("set cache succeed")
+			c.hashLock.Lock()
 			c.latestHash = resMd5
+			c.hashLock.Unlock()
 		}
 	} else {
 		//结果未更新则根据设置判断是否需要续期
@@ -113,12 +295,39 @@ func (c *Cache) saveToCache(ctx context.Context, res []byte) {
 	}
 }
 
+//lock 获取缓存的分布式锁
+//设置了WaitLockTimeout时会在该时间内等待锁的持有者释放锁,超时未获得锁返回lock.ErrAlreadyLocked
+//@returns int64 获得锁时得到的fencing token,锁不支持fencing token时为0
+func (c *Cache) lock() (int64, error) {
+	var err error
+	if c.opt.WaitLockTimeout > 0 {
+		err = c.opt.Lock.TryLockFor(context.Background(), c.opt.WaitLockTimeout)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), c.opt.QueryLockTimeout)
+		err = c.opt.Lock.Lock(ctx)
+		cancel()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return c.lockToken(), nil
+}
+
+//unlock 释放缓存的分布式锁
+func (c *Cache) unlock() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opt.QueryLockTimeout)
+	defer cancel()
+	err := c.opt.Lock.Unlock(ctx)
+	if err != nil {
+		c.Logger().Warn("cache's lock unlock get error", map[string]any{"err": err.Error()})
+	}
+}
+
 //ActiveUpdate 主动更新函数,可以被用作定时主动更新,也可以通过监听外部信号来实现更新
 func (c *Cache) ActiveUpdate() {
+	var token int64
 	if c.opt.Lock != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), c.opt.QueryLockTimeout)
-		defer cancel()
-		err := c.opt.Lock.Lock(ctx)
+		t, err := c.lock()
 		if err != nil {
 			if err == lock.ErrAlreadyLocked {
 				c.Logger().Info("locked,not do process")
@@ -127,8 +336,15 @@ func (c *Cache) ActiveUpdate() {
 				c.Logger().Error("lock error", map[string]any{"err": err.Error()})
 			}
 		}
-		defer c.opt.Lock.Unlock(ctx)
+		token = t
+		defer c.unlock()
 	}
+	c.refresh(token)
+}
+
+//refresh 执行缓存函数并将结果更新到缓存,调用方负责加锁
+//@params token int64 获得锁时得到的fencing token,没有时为0
+func (c *Cache) refresh(token int64) {
 	res, err := c.update(ForceLevel__STRICT)
 	if err != nil {
 		c.Logger().Error("do update process get error", map[string]any{"err": err.Error()})
@@ -137,7 +353,7 @@ func (c *Cache) ActiveUpdate() {
 	ctx1, cancel1 := context.WithTimeout(context.Background(), c.opt.QueryAutoUpdateCacheTimeout)
 	defer cancel1()
 	if len(res) > 0 {
-		c.saveToCache(ctx1, res)
+		c.saveToCache(ctx1, res, token)
 	} else {
 		//获取的数据为空
 		switch c.opt.EmptyResCacheMode {
@@ -157,10 +373,10 @@ func (c *Cache) ActiveUpdate() {
 					c.Logger().Warn("efreshTT key get error", map[string]any{"err": err.Error()})
 				}
 			}
-		case EmptyResCacheMode__SAVE:
+		case EmptyResCacheMode__SAVE, EmptyResCacheMode__NEGATIVE:
 			{
 				c.Logger().Debug("get empty result,also save")
-				c.saveToCache(ctx1, res)
+				c.saveToCache(ctx1, res, token)
 			}
 		}
 	}
@@ -199,10 +415,9 @@ func (c *Cache) lazyUpdate(ctx context.Context, force ForceLevelType) ([]byte, e
 	//
 	callback := func(ctx context.Context, res []byte) {
 		//锁限制重复写入缓存
+		var token int64
 		if c.opt.Lock != nil {
-			ctx1, cancel := context.WithTimeout(context.Background(), c.opt.QueryLockTimeout)
-			defer cancel()
-			err := c.opt.Lock.Lock(ctx1)
+			t, err := c.lock()
 			if err != nil {
 				if err == lock.ErrAlreadyLocked {
 					c.Logger().Error("cache locked")
@@ -211,9 +426,10 @@ func (c *Cache) lazyUpdate(ctx context.Context, force ForceLevelType) ([]byte, e
 					c.Logger().Error("cache's lock get error", map[string]any{"err": err.Error()})
 				}
 			}
-			defer c.opt.Lock.Unlock(ctx1)
+			token = t
+			defer c.unlock()
 		}
-		c.saveToCache(ctx, res)
+		c.saveToCache(ctx, res, token)
 	}
 
 	if len(res) > 0 {
@@ -246,7 +462,7 @@ func (c *Cache) lazyUpdate(ctx context.Context, force ForceLevelType) ([]byte, e
 				}()
 
 			}
-		case EmptyResCacheMode__SAVE:
+		case EmptyResCacheMode__SAVE, EmptyResCacheMode__NEGATIVE:
 			{
 				c.Logger().Debug("get empty result,also save")
 				go callback(ctx, res)
@@ -257,6 +473,38 @@ func (c *Cache) lazyUpdate(ctx context.Context, force ForceLevelType) ([]byte, e
 	return res, nil
 }
 
+//load 合并同一进程内并发的缓存未命中
+//同一时间每种强制执行类型只有一个goroutine执行lazyUpdate,相同类型的其他goroutine等待并共享它的结果,不同类型的调用不会共享结果,跨进程的重复计算依然由分布式锁限制
+//共享的加载在后台使用不会被取消的ctx执行,等待的调用方的ctx结束时直接返回ctx的错误,不影响加载继续执行
+//注意共享结果时返回的是同一个[]byte,调用方不应修改它
+func (c *Cache) load(ctx context.Context, force ForceLevelType) ([]byte, error) {
+	c.flightLock.Lock()
+	if c.flight == nil {
+		c.flight = map[ForceLevelType]*call{}
+	}
+	f, ok := c.flight[force]
+	if !ok {
+		f = &call{done: make(chan struct{})}
+		c.flight[force] = f
+		go func() {
+			defer func() {
+				c.flightLock.Lock()
+				delete(c.flight, force)
+				c.flightLock.Unlock()
+				close(f.done)
+			}()
+			f.res, f.err = c.lazyUpdate(detachedContext{ctx}, force)
+		}()
+	}
+	c.flightLock.Unlock()
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // 读操作
 
 type GetOptions struct {
@@ -290,14 +538,15 @@ func ConstraintMode() optparams.Option[GetOptions] {
 
 //Get 获取数据
 //如果缓存中有就从缓存中获取,如果没有则直接从注册的缓存函数中获取,然后将结果更新到缓存
+//同一进程内并发的缓存未命中只会执行一次注册的缓存函数并共享结果
 //如果cache的key有设置MaxTTL则在获取到缓存的数据是会刷新key的过期时间
 //@param opt ...optparams.Option[GetOptions] 获取模式设置
 func (c *Cache) Get(ctx context.Context, opt ...optparams.Option[GetOptions]) ([]byte, error) {
 	p := defaultGetOptions
 	optparams.GetOption(&p, opt...)
-	ress, err := c.Client().Get(ctx, c.Key()).Result()
+	ress, near, err := c.getRaw(ctx)
 	if err != nil {
-		res, err1 := c.lazyUpdate(ctx, p.Force)
+		res, err1 := c.load(ctx, p.Force)
 		if err1 != nil {
 			log.Debug("从函数获取失败")
 			return nil, err1
@@ -310,13 +559,28 @@ func (c *Cache) Get(ctx context.Context, opt ...optparams.Option[GetOptions]) ([
 		return res, nil
 	}
 	log.Debug("从缓存成功获取")
-	go func() {
-		if c.MaxTTL() > 0 {
-			err := c.RefreshTTL(ctx)
+	data := []byte(ress)
+	if c.staleEnabled() {
+		var softExpireAt time.Time
+		var ok bool
+		data, softExpireAt, ok = c.unwrapStale(data)
+		if !ok {
+			c.Logger().Warn("cache data without soft expire header")
+			return c.load(ctx, p.Force)
+		}
+		if c.shouldRevalidate(softExpireAt) {
+			go c.revalidate()
+		}
+	}
+	//近端缓存命中时不访问redis,过期时间在从redis读取时刷新
+	//刷新在调用方返回后执行,不能使用可能已经取消的调用方ctx
+	if !near && c.MaxTTL() > 0 {
+		go func() {
+			err := c.RefreshTTL(detachedContext{ctx})
 			if err != nil {
 				c.Logger().Warn("RefreshTTL key get error", map[string]any{"err": err.Error()})
 			}
-		}
-	}()
-	return []byte(ress), nil
+		}()
+	}
+	return data, nil
 }
@@ -3,11 +3,14 @@ package cache
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Golang-Tools/idgener"
 	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
 	"github.com/Golang-Tools/redishelper/v2/incrlimiter"
 
 	"github.com/Golang-Tools/redishelper/v2/lock"
@@ -262,3 +265,163 @@ func Test_new_cache_with_limiter(t *testing.T) {
 		assert.Equal(t, ErrLimiterNotAllow, err)
 	}
 }
+
+//测试fencing token防止旧的锁持有者覆盖新值
+func Test_cache_fencing_token(t *testing.T) {
+	// 准备工作
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	lock1, err := lock.New(cli, lock.WithSpecifiedKey("test_cache_lock"), lock.WithMaxTTL(time.Second), lock.WithClientID("cache_lock1"), lock.WithFencingToken())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "create lock1 error")
+	}
+	lock2, err := lock.New(cli, lock.WithSpecifiedKey("test_cache_lock"), lock.WithMaxTTL(time.Second), lock.WithClientID("cache_lock2"), lock.WithFencingToken())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "create lock2 error")
+	}
+	cache1, err := New(cli, WithSpecifiedKey("test_cache"), WithLock(lock1))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache1 error")
+	}
+	//记录token的键与缓存落在同一个集群slot
+	assert.Equal(t, "{test_cache}::fencing", cache1.FencingKey())
+	cache2, err := New(cli, WithSpecifiedKey("test_cache"), WithLock(lock2))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache2 error")
+	}
+	err = lock1.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock1 lock error")
+	}
+	token1 := lock1.Token()
+	time.Sleep(1500 * time.Millisecond)
+	err = lock2.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 lock error")
+	}
+	token2 := lock2.Token()
+	err = cache2.set(ctx, []byte("new"), token2)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "cache2 set error")
+	}
+	err = cache1.set(ctx, []byte("old"), token1)
+	assert.Equal(t, ErrStaleFencingToken, err)
+	//启用了fencing token但没有token时拒绝写入
+	err = cache1.set(ctx, []byte("old"), 0)
+	assert.Equal(t, ErrFencingTokenUnavailable, err)
+	res, err := cli.Get(ctx, "test_cache").Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "new", res)
+}
+
+//测试同一进程内并发的缓存未命中只执行一次更新函数
+func Test_cache_singleflight(t *testing.T) {
+	// 准备工作
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int32
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(200 * time.Millisecond)
+		return []byte("ok"), nil
+	})
+	var wg sync.WaitGroup
+	results := make(chan []byte, 100)
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := cache.Get(ctx, StrictMode())
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- res
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err, "cache.Get error")
+	}
+	for res := range results {
+		assert.Equal(t, []byte("ok"), res)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
+
+func Test_cache_singleflight_ctx(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int32
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(300 * time.Millisecond)
+		return []byte("ok"), nil
+	})
+	//发起加载的调用方超时不影响共享的加载,等待的调用方可以拿到结果
+	ctx1, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(ctx1, StrictMode())
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	res, err := cache.Get(ctx, StrictMode())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "cache.Get error")
+	}
+	assert.Equal(t, []byte("ok"), res)
+	assert.Equal(t, context.DeadlineExceeded, <-done)
+	//等待的调用方ctx结束时直接返回
+	cache.Delete(ctx)
+	ctx2, cancel2 := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel2()
+	start := time.Now()
+	_, err = cache.Get(ctx2, StrictMode())
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
+
+//测试不同强制执行类型的并发加载不共享结果,相同类型的依然合并
+func Test_cache_singleflight_force_level(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int32
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		atomic.AddInt32(&count, 1)
+		time.Sleep(200 * time.Millisecond)
+		return []byte("ok"), nil
+	})
+	var wg sync.WaitGroup
+	for _, mode := range []optparams.Option[GetOptions]{StrictMode(), StrictMode(), NoConstraintMode(), NoConstraintMode()} {
+		wg.Add(1)
+		go func(mode optparams.Option[GetOptions]) {
+			defer wg.Done()
+			res, err := cache.Get(ctx, mode)
+			assert.NoError(t, err, "cache.Get error")
+			assert.Equal(t, []byte("ok"), res)
+		}(mode)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/Golang-Tools/redishelper/v2/pchelper"
+	"google.golang.org/protobuf/proto"
+)
+
+//Codec 缓存值的编解码器
+type Codec interface {
+	//Marshal 将值序列化为字节串
+	Marshal(v any) ([]byte, error)
+	//Unmarshal 将字节串反序列化到v,v必须为指针
+	Unmarshal(data []byte, v any) error
+}
+
+//JSONCodec 使用json作为序列化协议,与pchelper使用相同的json设置
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return pchelper.Marshal(pchelper.SerializeProtocol_JSON, v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return pchelper.Unmarshal(pchelper.SerializeProtocol_JSON, data, v)
+}
+
+//MsgpackCodec 使用messagepack作为序列化协议
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return pchelper.Marshal(pchelper.SerializeProtocol_MSGPACK, v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return pchelper.Unmarshal(pchelper.SerializeProtocol_MSGPACK, data, v)
+}
+
+//GobCodec 使用gob作为序列化协议
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//ProtobufCodec 使用protobuf作为序列化协议,值必须满足proto.Message接口
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+//CodecFromSerializeProtocol 根据pchelper中定义的序列化协议获取对应的编解码器
+func CodecFromSerializeProtocol(protocol pchelper.SerializeProtocolType) (Codec, error) {
+	switch protocol {
+	case pchelper.SerializeProtocol_JSON:
+		{
+			return JSONCodec{}, nil
+		}
+	case pchelper.SerializeProtocol_MSGPACK:
+		{
+			return MsgpackCodec{}, nil
+		}
+	default:
+		{
+			return nil, pchelper.ErrUnSupportSerializeProtocol
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+//压缩后的数据以1字节的头标识压缩算法
+const (
+	//compressFlag__NONE 数据未压缩
+	compressFlag__NONE byte = iota
+	//compressFlag__ZSTD 数据使用zstd压缩
+	compressFlag__ZSTD
+	//compressFlag__SNAPPY 数据使用snappy压缩
+	compressFlag__SNAPPY
+)
+
+//Compressor 缓存值的压缩器
+type Compressor interface {
+	//Flag 压缩算法的标识,会写入数据的头部,不能为0
+	Flag() byte
+	//Compress 压缩数据
+	Compress(src []byte) ([]byte, error)
+	//Decompress 解压数据
+	Decompress(src []byte) ([]byte, error)
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+//initZstd 初始化共用的zstd编解码器
+func initZstd() {
+	zstdEncoder, zstdErr = zstd.NewWriter(nil)
+	if zstdErr != nil {
+		return
+	}
+	zstdDecoder, zstdErr = zstd.NewReader(nil)
+}
+
+//ZstdCompressor 使用zstd压缩
+type ZstdCompressor struct{}
+
+func (ZstdCompressor) Flag() byte {
+	return compressFlag__ZSTD
+}
+
+func (ZstdCompressor) Compress(src []byte) ([]byte, error) {
+	zstdOnce.Do(initZstd)
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdEncoder.EncodeAll(src, nil), nil
+}
+
+func (ZstdCompressor) Decompress(src []byte) ([]byte, error) {
+	zstdOnce.Do(initZstd)
+	if zstdErr != nil {
+		return nil, zstdErr
+	}
+	return zstdDecoder.DecodeAll(src, nil)
+}
+
+//SnappyCompressor 使用snappy压缩
+type SnappyCompressor struct{}
+
+func (SnappyCompressor) Flag() byte {
+	return compressFlag__SNAPPY
+}
+
+func (SnappyCompressor) Compress(src []byte) ([]byte, error) {
+	return snappy.Encode(nil, src), nil
+}
+
+func (SnappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return snappy.Decode(nil, src)
+}
+
+//compressors 内置的压缩器,用于解压由其他压缩器写入的数据
+var compressors = map[byte]Compressor{
+	compressFlag__ZSTD:   ZstdCompressor{},
+	compressFlag__SNAPPY: SnappyCompressor{},
+}
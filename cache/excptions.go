@@ -15,3 +15,33 @@ var ErrAutoUpdateAlreadyStarted = errors.New("auto update already started")
 
 //ErrLimiterNotAllow 限制器不允许执行更新任务
 var ErrLimiterNotAllow = errors.New("limiter not allow")
+
+//ErrStaleFencingToken 写入缓存使用的fencing token比已写入的旧
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+//ErrFencingTokenUnavailable 锁启用了fencing token但写入缓存时没有可用的token
+var ErrFencingTokenUnavailable = errors.New("fencing token unavailable")
+
+//ErrNotProtoMessage 使用ProtobufCodec时值必须满足proto.Message接口
+var ErrNotProtoMessage = errors.New("value not proto.Message")
+
+//ErrUnknownCompressFlag 数据头部的压缩标识无法识别
+var ErrUnknownCompressFlag = errors.New("unknown compress flag")
+
+//ErrLoaderNotRegisted 多条目缓存未注册加载函数
+var ErrLoaderNotRegisted = errors.New("loader not registed")
+
+//ErrEntryNotExists 条目在缓存和加载函数中都不存在
+var ErrEntryNotExists = errors.New("entry not exists")
+
+//ErrSoftTTLMustLessThanMaxTTL 软过期时间必须小于MaxTTL
+var ErrSoftTTLMustLessThanMaxTTL = errors.New("soft ttl must less than max ttl")
+
+//ErrTrackingNeedSingleClient CLIENT TRACKING模式只支持单机客户端
+var ErrTrackingNeedSingleClient = errors.New("client tracking need *redis.Client")
+
+//ErrBloomFilterParams 布隆过滤器的容量需要大于0,误判率需要在(0,1)之间
+var ErrBloomFilterParams = errors.New("bloom filter capacity must larger than 0 and error rate must between 0 and 1")
+
+//ErrRefreshNeedSource 事件触发刷新至少需要一个事件源
+var ErrRefreshNeedSource = errors.New("refresh need keyspace or stream source")
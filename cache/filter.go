@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+
+	"github.com/Golang-Tools/redishelper/v2/bitmapset"
+	bloomfilterhelper "github.com/Golang-Tools/redishelper/v2/exthelper/redisbloomhelper/bloomfilter"
+	"github.com/go-redis/redis/v8"
+)
+
+//ExistenceFilter 判断条目是否可能存在的过滤器
+//KeyedCache在调用加载函数前用它过滤掉一定不存在的条目,防止对不存在数据的查询穿透到数据源
+//过滤器中的元素为条目键的字符串形式(fmt.Sprint(key))
+type ExistenceFilter interface {
+	//MightContain 检查元素是否可能存在,返回false表示一定不存在
+	//@params items ...string 待检查的元素
+	MightContain(ctx context.Context, items ...string) (map[string]bool, error)
+	//Add 记录存在的元素
+	//@params items ...string 存在的元素
+	Add(ctx context.Context, items ...string) error
+}
+
+//SeededFilter 可以报告是否已经写入了所有存在的元素的过滤器
+//空的或只写入了部分元素的过滤器会把存在的条目判断为不存在,因此KeyedCache在Seeded返回true之前不会使用它过滤
+type SeededFilter interface {
+	//Seeded 是否已经写入了所有存在的元素
+	Seeded(ctx context.Context) (bool, error)
+}
+
+//seededKey 记录过滤器已经写入了所有存在的元素的键
+func seededKey(key string) string {
+	return key + "::seeded"
+}
+
+//RedisBloomFilter 使用RedisBloom模块布隆过滤器的过滤器
+type RedisBloomFilter struct {
+	bf *bloomfilterhelper.BloomFilter
+}
+
+//NewRedisBloomFilter 使用RedisBloom模块的布隆过滤器创建过滤器
+//@params bf *bloomfilterhelper.BloomFilter 布隆过滤器对象
+func NewRedisBloomFilter(bf *bloomfilterhelper.BloomFilter) *RedisBloomFilter {
+	return &RedisBloomFilter{bf: bf}
+}
+
+//MightContain 检查元素是否可能存在,返回false表示一定不存在
+//@params items ...string 待检查的元素
+func (f *RedisBloomFilter) MightContain(ctx context.Context, items ...string) (map[string]bool, error) {
+	if len(items) == 0 {
+		return map[string]bool{}, nil
+	}
+	return f.bf.MExistsItem(ctx, items...)
+}
+
+//Add 记录存在的元素
+//@params items ...string 存在的元素
+func (f *RedisBloomFilter) Add(ctx context.Context, items ...string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := f.bf.MAddItem(ctx, items)
+	return err
+}
+
+//Seed 写入所有存在的元素并标记过滤器已经可以使用,可以分多次写入,最后一次写入时标记
+//@params items ...string 存在的元素
+func (f *RedisBloomFilter) Seed(ctx context.Context, items ...string) error {
+	err := f.Add(ctx, items...)
+	if err != nil {
+		return err
+	}
+	return f.bf.Client().Set(ctx, seededKey(f.bf.Key()), "1", 0).Err()
+}
+
+//Seeded 是否已经使用Seed写入了所有存在的元素
+func (f *RedisBloomFilter) Seeded(ctx context.Context) (bool, error) {
+	n, err := f.bf.Client().Exists(ctx, seededKey(f.bf.Key())).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+//BitmapBloomFilter 在redis不能加载RedisBloom模块时使用的基于bitmap的布隆过滤器
+//使用double hashing从一次fnv哈希中得到多个哈希值
+type BitmapBloomFilter struct {
+	bm     *bitmapset.Bitmap
+	bits   uint64
+	hashes int
+}
+
+//NewBitmapBloomFilter 使用bitmap创建布隆过滤器
+//位数和哈希函数的个数根据预期容量和误判率计算,与BF.RESERVE的参数含义一致
+//@params bm *bitmapset.Bitmap 存放布隆过滤器的bitmap
+//@params capacity uint64 预期容纳的元素个数
+//@params errorRate float64 预期的误判率,取值范围(0,1)
+func NewBitmapBloomFilter(bm *bitmapset.Bitmap, capacity uint64, errorRate float64) (*BitmapBloomFilter, error) {
+	if capacity == 0 || errorRate <= 0 || errorRate >= 1 {
+		return nil, ErrBloomFilterParams
+	}
+	bits := uint64(math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	hashes := int(math.Round(float64(bits) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BitmapBloomFilter{bm: bm, bits: bits, hashes: hashes}, nil
+}
+
+//offsets 元素在bitmap中对应的位
+func (f *BitmapBloomFilter) offsets(item string) []int64 {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	sum := h.Sum64()
+	h1 := sum & 0xffffffff
+	h2 := sum>>32 | 1
+	res := make([]int64, 0, f.hashes)
+	for i := 0; i < f.hashes; i++ {
+		res = append(res, int64((h1+uint64(i)*h2)%f.bits))
+	}
+	return res
+}
+
+//MightContain 检查元素是否可能存在,返回false表示一定不存在
+//@params items ...string 待检查的元素
+func (f *BitmapBloomFilter) MightContain(ctx context.Context, items ...string) (map[string]bool, error) {
+	result := map[string]bool{}
+	if len(items) == 0 {
+		return result, nil
+	}
+	cmds := make([][]*redis.IntCmd, 0, len(items))
+	_, err := f.bm.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			itemCmds := make([]*redis.IntCmd, 0, f.hashes)
+			for _, offset := range f.offsets(item) {
+				itemCmds = append(itemCmds, pipe.GetBit(ctx, f.bm.Key(), offset))
+			}
+			cmds = append(cmds, itemCmds)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		exists := true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				exists = false
+				break
+			}
+		}
+		result[item] = exists
+	}
+	return result, nil
+}
+
+//Add 记录存在的元素
+//@params items ...string 存在的元素
+func (f *BitmapBloomFilter) Add(ctx context.Context, items ...string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	offsets := make([]int64, 0, len(items)*f.hashes)
+	for _, item := range items {
+		offsets = append(offsets, f.offsets(item)...)
+	}
+	return f.bm.AddM(ctx, offsets...)
+}
+
+//Seed 写入所有存在的元素并标记过滤器已经可以使用,可以分多次写入,最后一次写入时标记
+//@params items ...string 存在的元素
+func (f *BitmapBloomFilter) Seed(ctx context.Context, items ...string) error {
+	err := f.Add(ctx, items...)
+	if err != nil {
+		return err
+	}
+	return f.bm.Client().Set(ctx, seededKey(f.bm.Key()), "1", 0).Err()
+}
+
+//Seeded 是否已经使用Seed写入了所有存在的元素
+func (f *BitmapBloomFilter) Seeded(ctx context.Context) (bool, error) {
+	n, err := f.bm.Client().Exists(ctx, seededKey(f.bm.Key())).Result()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/bitmapset"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func Test_cache_negative(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(10*time.Second), WithNegativeCache(time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int64
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		atomic.AddInt64(&count, 1)
+		return []byte{}, nil
+	})
+	res, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, 0, len(res))
+	time.Sleep(100 * time.Millisecond)
+	ttl, err := cli.PTTL(ctx, "test_cache").Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "PTTL error")
+	}
+	assert.LessOrEqual(t, ttl, time.Second)
+	assert.Greater(t, ttl, time.Duration(0))
+	res, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, 0, len(res))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+	//ttl不大于0时不开启负缓存
+	cache, err = New(cli, WithSpecifiedKey("test_cache"), WithNegativeCache(0))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	assert.NotEqual(t, EmptyResCacheMode__NEGATIVE, cache.opt.EmptyResCacheMode)
+}
+
+func Test_keyed_cache_negative_and_filter(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	bm, err := bitmapset.New(cli, bitmapset.WithSpecifiedKey("test_keyed_cache_filter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new bitmap error")
+	}
+	_, err = NewBitmapBloomFilter(bm, 0, 0.01)
+	assert.Equal(t, ErrBloomFilterParams, err)
+	filter, err := NewBitmapBloomFilter(bm, 1000, 0.01)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new filter error")
+	}
+	err = filter.Add(ctx, "1")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "add filter error")
+	}
+	seeded, err := filter.Seeded(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Seeded error")
+	}
+	assert.False(t, seeded)
+	err = filter.Seed(ctx, "2", "3")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "seed filter error")
+	}
+	exists, err := filter.MightContain(ctx, "1", "404")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "MightContain error")
+	}
+	assert.True(t, exists["1"])
+	assert.False(t, exists["404"])
+
+	cache, err := NewKeyed[int, string](cli, WithSpecifiedKey("test_keyed_cache"), WithNegativeCache(time.Second), WithExistenceFilter(filter))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	calls := [][]int{}
+	cache.RegistLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		calls = append(calls, keys)
+		res := map[int]string{}
+		for _, k := range keys {
+			if k != 3 {
+				res[k] = "value"
+			}
+		}
+		return res, nil
+	})
+	res, err := cache.GetMany(ctx, []int{1, 3, 404})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, map[int]string{1: "value"}, res)
+	//过滤器中不存在的404不会交给加载函数
+	assert.Equal(t, 1, len(calls))
+	assert.ElementsMatch(t, []int{1, 3}, calls[0])
+
+	//3作为负缓存不会再次交给加载函数
+	_, err = cache.Get(ctx, 3)
+	assert.Equal(t, ErrEntryNotExists, err)
+	assert.Equal(t, 1, len(calls))
+	ttl, err := cli.PTTL(ctx, cache.EntryKey(3)).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "PTTL error")
+	}
+	assert.LessOrEqual(t, ttl, time.Second)
+
+	//写入的条目会记录到过滤器
+	err = cache.Set(ctx, 5, "five")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Set error")
+	}
+	exists, err = filter.MightContain(ctx, "5")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "MightContain error")
+	}
+	assert.True(t, exists["5"])
+}
+
+func Test_keyed_cache_filter_not_seeded(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	bm, err := bitmapset.New(cli, bitmapset.WithSpecifiedKey("test_keyed_cache_filter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new bitmap error")
+	}
+	filter, err := NewBitmapBloomFilter(bm, 1000, 0.01)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new filter error")
+	}
+	cache, err := NewKeyed[int, string](cli, WithSpecifiedKey("test_keyed_cache"), WithExistenceFilter(filter))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		res := map[int]string{}
+		for _, k := range keys {
+			res[k] = "value"
+		}
+		return res, nil
+	})
+	//过滤器还没有写入所有存在的元素时不过滤
+	res, err := cache.GetMany(ctx, []int{1, 2})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, map[int]string{1: "value", 2: "value"}, res)
+}
+
+func Test_keyed_cache_negative_empty_value(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := NewKeyed[int, *wrapperspb.StringValue](cli, WithSpecifiedKey("test_keyed_cache"), WithNegativeCache(time.Second), WithCodec(ProtobufCodec{}))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistLoader(func(ctx context.Context, keys []int) (map[int]*wrapperspb.StringValue, error) {
+		res := map[int]*wrapperspb.StringValue{}
+		for _, k := range keys {
+			if k != 3 {
+				//默认值的proto消息编码结果为空
+				res[k] = &wrapperspb.StringValue{}
+			}
+		}
+		return res, nil
+	})
+	_, err = cache.GetMany(ctx, []int{1, 3})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	raw, err := cli.Get(ctx, cache.EntryKey(1)).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Get raw error")
+	}
+	assert.Equal(t, string([]byte{entryValue}), raw)
+	//编码结果为空的值不会被当作负缓存
+	res, err := cache.GetMany(ctx, []int{1, 3})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "", res[1].GetValue())
+	_, err = cache.Get(ctx, 3)
+	assert.Equal(t, ErrEntryNotExists, err)
+}
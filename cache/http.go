@@ -0,0 +1,567 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/lock"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//HTTPOptions http响应缓存中间件的配置
+type HTTPOptions struct {
+	Methods         []string                         //缓存的请求方法
+	VaryHeaders     []string                         //参与计算缓存键的请求头
+	QueryParams     []string                         //参与计算缓存键的查询参数,为nil时使用全部查询参数
+	IgnoreQuery     bool                             //是否忽略查询参数
+	CacheableStatus []int                            //可以缓存的响应状态码
+	StatusHeader    string                           //标记缓存是否命中的响应头,为空时不设置
+	Coalesce        bool                             //是否使用分布式锁合并多个副本上并发的缓存未命中
+	LockWait        time.Duration                    //锁被占用时等待持有者写入缓存的最长时间
+	LockTTL         time.Duration                    //锁的过期时间,避免持有者异常退出后锁一直存在
+	LockOpts        []optparams.Option[lock.Options] //创建锁的配置
+	CacheOpts       []optparams.Option[Options]      //缓存的配置,编解码器,压缩,近端缓存,标签等设置都对响应缓存有效
+}
+
+//defaultHTTPOptions http响应缓存中间件的默认配置
+var defaultHTTPOptions = HTTPOptions{
+	Methods:         []string{http.MethodGet, http.MethodHead},
+	CacheableStatus: []int{http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone},
+	StatusHeader:    "X-Cache",
+	LockWait:        3 * time.Second,
+	LockTTL:         10 * time.Second,
+	LockOpts:        []optparams.Option[lock.Options]{},
+	CacheOpts:       []optparams.Option[Options]{},
+}
+
+//WithHTTPMethods 设置缓存的请求方法,默认GET和HEAD
+func WithHTTPMethods(methods ...string) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.Methods = methods
+	})
+}
+
+//WithHTTPVaryHeaders 设置参与计算缓存键的请求头
+func WithHTTPVaryHeaders(headers ...string) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.VaryHeaders = append(o.VaryHeaders, headers...)
+	})
+}
+
+//WithHTTPQueryParams 设置参与计算缓存键的查询参数,不设置时使用全部查询参数
+func WithHTTPQueryParams(params ...string) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.QueryParams = append(o.QueryParams, params...)
+	})
+}
+
+//WithHTTPIgnoreQuery 设置计算缓存键时忽略查询参数
+func WithHTTPIgnoreQuery() optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.IgnoreQuery = true
+	})
+}
+
+//WithHTTPCacheableStatus 设置可以缓存的响应状态码
+func WithHTTPCacheableStatus(status ...int) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.CacheableStatus = status
+	})
+}
+
+//WithHTTPStatusHeader 设置标记缓存是否命中的响应头,默认X-Cache,设置为空字符串时不设置
+func WithHTTPStatusHeader(header string) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.StatusHeader = header
+	})
+}
+
+//WithHTTPCoalesce 使用分布式锁合并多个副本上并发的缓存未命中
+//同一个缓存键只有获得锁的请求会执行处理函数,其他请求等待它写入缓存后直接读取缓存
+//@params wait time.Duration 锁被占用时等待的最长时间,超时后直接执行处理函数
+//@params opts ...optparams.Option[lock.Options] 创建锁的配置
+func WithHTTPCoalesce(wait time.Duration, opts ...optparams.Option[lock.Options]) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.Coalesce = true
+		o.LockWait = wait
+		o.LockOpts = append(o.LockOpts, opts...)
+	})
+}
+
+//WithHTTPLockTTL 设置合并缓存未命中时使用的锁的过期时间,默认10s
+func WithHTTPLockTTL(ttl time.Duration) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.LockTTL = ttl
+	})
+}
+
+//WithHTTPCacheOptions 设置缓存的配置
+func WithHTTPCacheOptions(opts ...optparams.Option[Options]) optparams.Option[HTTPOptions] {
+	return optparams.NewFuncOption(func(o *HTTPOptions) {
+		o.CacheOpts = append(o.CacheOpts, opts...)
+	})
+}
+
+//httpEntry 缓存的http响应
+type httpEntry struct {
+	Status   int         `json:"status" msgpack:"status"`
+	Header   http.Header `json:"header" msgpack:"header"`
+	Body     []byte      `json:"body" msgpack:"body"`
+	StoredAt int64       `json:"stored_at" msgpack:"stored_at"`           //写入缓存的时间(unix ms)
+	Vary     []string    `json:"vary,omitempty" msgpack:"vary,omitempty"` //不为空时说明这是响应Vary的请求头列表,实际响应保存在按这些请求头计算的变体键中
+}
+
+//HTTPCache http响应缓存中间件
+//缓存键由请求方法,路径,选定的请求头和查询参数计算得到,响应的状态码,响应头和响应体使用缓存设置的编解码器序列化后保存
+//响应的过期时间优先使用响应Cache-Control中的s-maxage或max-age,没有时使用MaxTTL,设置了MaxTTL时不会超过MaxTTL
+//请求带有Cache-Control: no-store时不使用缓存,带有no-cache时不读取缓存;响应带有no-store,no-cache,private,Set-Cookie或Vary: *时不写入缓存
+//请求带有Authorization或Cookie时,只有响应的Cache-Control带有public,s-maxage或must-revalidate时才会读取和写入缓存
+//响应带有Vary时,Vary中的请求头也会参与计算缓存键
+//响应没有ETag时会根据响应体生成ETag,请求的If-None-Match与ETag匹配时返回304
+//注意处理函数的响应会先被完整缓冲再写出,因此不适合用于流式响应
+type HTTPCache struct {
+	*middlewarehelper.MiddleWareAbc
+	opt     Options
+	httpopt HTTPOptions
+	methods map[string]bool
+	status  map[int]bool
+}
+
+//NewHTTPCache 创建一个http响应缓存中间件
+func NewHTTPCache(cli redis.UniversalClient, opts ...optparams.Option[HTTPOptions]) (*HTTPCache, error) {
+	c := new(HTTPCache)
+	c.httpopt = defaultHTTPOptions
+	optparams.GetOption(&c.httpopt, opts...)
+	c.opt = Defaultopt
+	optparams.GetOption(&c.opt, c.httpopt.CacheOpts...)
+	m, err := middlewarehelper.New(cli, "cache", c.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.MiddleWareAbc = m
+	c.methods = map[string]bool{}
+	for _, method := range c.httpopt.Methods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	c.status = map[int]bool{}
+	for _, status := range c.httpopt.CacheableStatus {
+		c.status[status] = true
+	}
+	return c, nil
+}
+
+//RequestKey 请求对应的缓存在redis中使用的键
+//@params r *http.Request 请求
+func (c *HTTPCache) RequestKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteString("\n")
+	b.WriteString(r.URL.Path)
+	for _, name := range c.httpopt.VaryHeaders {
+		b.WriteString("\n")
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteString(":")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	if !c.httpopt.IgnoreQuery {
+		query := r.URL.Query()
+		if c.httpopt.QueryParams != nil {
+			selected := url.Values{}
+			for _, param := range c.httpopt.QueryParams {
+				if values, ok := query[param]; ok {
+					selected[param] = values
+				}
+			}
+			query = selected
+		}
+		for _, values := range query {
+			sort.Strings(values)
+		}
+		b.WriteString("\n")
+		b.WriteString(query.Encode())
+	}
+	h := md5.New()
+	h.Write([]byte(b.String()))
+	return c.Key() + "::" + hex.EncodeToString(h.Sum(nil))
+}
+
+//Middleware 包装http处理函数
+//@params next http.Handler 被包装的处理函数
+func (c *HTTPCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.methods[r.Method] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		directives := parseCacheControl(r.Header.Get("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := c.RequestKey(r)
+		_, noCache := directives["no-cache"]
+		if !noCache {
+			entry, ok := c.find(r, key)
+			if ok {
+				c.serve(w, r, entry, true)
+				return
+			}
+		}
+		c.fill(w, r, next, key, !noCache)
+	})
+}
+
+//lookup 从缓存中读取响应
+func (c *HTTPCache) lookup(ctx context.Context, key string) (*httpEntry, bool) {
+	var res string
+	var ok bool
+	if c.opt.Near != nil {
+		res, ok = c.opt.Near.get(key)
+	}
+	if !ok {
+		var err error
+		res, err = c.Client().Get(ctx, key).Result()
+		if err != nil {
+			if err != redis.Nil {
+				c.Logger().Warn("http cache get error", map[string]any{"err": err.Error()})
+			}
+			return nil, false
+		}
+		if c.opt.Near != nil {
+			c.opt.Near.set(key, res)
+		}
+	}
+	entry := new(httpEntry)
+	err := c.opt.decode([]byte(res), entry)
+	if err != nil {
+		c.Logger().Warn("http cache decode error", map[string]any{"err": err.Error()})
+		return nil, false
+	}
+	return entry, true
+}
+
+//find 读取请求对应的缓存
+//缓存的是响应Vary的请求头列表时,按请求中这些请求头的值读取对应的变体
+//请求带有Authorization或Cookie时,只返回允许被共享的响应
+func (c *HTTPCache) find(r *http.Request, key string) (*httpEntry, bool) {
+	entry, ok := c.lookup(r.Context(), key)
+	if !ok {
+		return nil, false
+	}
+	if len(entry.Vary) > 0 {
+		entry, ok = c.lookup(r.Context(), variantKey(key, r, entry.Vary))
+		if !ok {
+			return nil, false
+		}
+	}
+	if hasCredentials(r) && !sharedCacheable(parseCacheControl(entry.Header.Get("Cache-Control"))) {
+		return nil, false
+	}
+	return entry, true
+}
+
+//fill 缓存未命中时执行处理函数并写入缓存
+//开启Coalesce时只有获得锁的请求执行处理函数,其他请求等待锁释放后重新读取缓存
+func (c *HTTPCache) fill(w http.ResponseWriter, r *http.Request, next http.Handler, key string, recheck bool) {
+	if c.httpopt.Coalesce {
+		l, err := c.newLock(key)
+		if err != nil {
+			c.Logger().Error("http cache create lock error", map[string]any{"err": err.Error()})
+		} else {
+			err = l.Lock(r.Context())
+			switch err {
+			case nil:
+				{
+					defer func() {
+						err := l.Unlock(context.Background())
+						if err != nil {
+							c.Logger().Warn("http cache unlock error", map[string]any{"err": err.Error()})
+						}
+					}()
+					//获得锁之前可能已经有其他副本写入了缓存
+					if recheck {
+						if entry, ok := c.find(r, key); ok {
+							c.serve(w, r, entry, true)
+							return
+						}
+					}
+				}
+			case lock.ErrAlreadyLocked:
+				{
+					ctx, cancel := context.WithTimeout(r.Context(), c.httpopt.LockWait)
+					err := l.Wait(ctx)
+					cancel()
+					if err != nil {
+						c.Logger().Warn("http cache wait lock error", map[string]any{"err": err.Error()})
+					} else if recheck {
+						if entry, ok := c.find(r, key); ok {
+							c.serve(w, r, entry, true)
+							return
+						}
+					}
+				}
+			default:
+				{
+					c.Logger().Error("http cache lock error", map[string]any{"err": err.Error()})
+				}
+			}
+		}
+	}
+	rec := newResponseRecorder()
+	next.ServeHTTP(rec, r)
+	entry := &httpEntry{
+		Status:   rec.status,
+		Header:   rec.header,
+		Body:     rec.body.Bytes(),
+		StoredAt: time.Now().UnixMilli(),
+	}
+	if entry.Header.Get("ETag") == "" && c.status[entry.Status] {
+		h := md5.New()
+		h.Write(entry.Body)
+		entry.Header.Set("ETag", `"`+hex.EncodeToString(h.Sum(nil))+`"`)
+	}
+	if ttl, ok := c.storeTTL(r, entry); ok {
+		storeKey := key
+		vary, _ := responseVary(entry.Header)
+		if len(vary) > 0 {
+			//原始键只保存Vary的请求头列表,响应保存在变体键中
+			err := c.store(r.Context(), key, &httpEntry{Vary: vary, StoredAt: entry.StoredAt}, ttl)
+			if err != nil {
+				c.Logger().Warn("http cache set error", map[string]any{"err": err.Error()})
+			}
+			storeKey = variantKey(key, r, vary)
+		}
+		err := c.store(r.Context(), storeKey, entry, ttl)
+		if err != nil {
+			c.Logger().Warn("http cache set error", map[string]any{"err": err.Error()})
+		}
+	}
+	c.serve(w, r, entry, false)
+}
+
+//newLock 创建缓存键对应的锁,每个锁使用独立的ClientID避免同一台机器上的副本互相解锁
+func (c *HTTPCache) newLock(key string) (*lock.Lock, error) {
+	opts := append([]optparams.Option[lock.Options]{lock.WithCheckPeriod(10 * time.Millisecond)}, c.httpopt.LockOpts...)
+	opts = append(opts,
+		lock.WithSpecifiedKey(key+"::lock"),
+		lock.WithMaxTTL(c.httpopt.LockTTL),
+		lock.WithClientID(hex.EncodeToString(uuid.NewV4().Bytes())),
+	)
+	return lock.New(c.Client(), opts...)
+}
+
+//storeTTL 计算响应的缓存时长
+//@returns bool 响应是否可以缓存
+func (c *HTTPCache) storeTTL(r *http.Request, entry *httpEntry) (time.Duration, bool) {
+	if !c.status[entry.Status] {
+		return 0, false
+	}
+	if entry.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	if _, star := responseVary(entry.Header); star {
+		return 0, false
+	}
+	directives := parseCacheControl(entry.Header.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0, false
+		}
+	}
+	if hasCredentials(r) && !sharedCacheable(directives) {
+		return 0, false
+	}
+	ttl := c.MaxTTL()
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			seconds, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			ttl = time.Duration(seconds) * time.Second
+			if c.MaxTTL() > 0 && ttl > c.MaxTTL() {
+				ttl = c.MaxTTL()
+			}
+			break
+		}
+	}
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+//store 将响应写入缓存
+func (c *HTTPCache) store(ctx context.Context, key string, entry *httpEntry, ttl time.Duration) error {
+	data, err := c.opt.encode(entry)
+	if err != nil {
+		return err
+	}
+	err = c.Client().Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return err
+	}
+	if c.opt.Near != nil {
+		err := c.opt.Near.Invalidate(ctx, key)
+		if err != nil {
+			c.Logger().Warn("invalidate near cache get error", map[string]any{"err": err.Error()})
+		}
+	}
+	return addTags(ctx, c.Client(), &c.opt, taggedKey{key: key, ttl: ttl.Milliseconds(), tags: c.opt.Tags})
+}
+
+//serve 将响应写出,请求的If-None-Match与ETag匹配时返回304
+func (c *HTTPCache) serve(w http.ResponseWriter, r *http.Request, entry *httpEntry, hit bool) {
+	header := w.Header()
+	for k, v := range entry.Header {
+		header[k] = append([]string{}, v...)
+	}
+	if c.httpopt.StatusHeader != "" {
+		if hit {
+			header.Set(c.httpopt.StatusHeader, "HIT")
+		} else {
+			header.Set(c.httpopt.StatusHeader, "MISS")
+		}
+	}
+	if hit {
+		age := (time.Now().UnixMilli() - entry.StoredAt) / 1000
+		if age < 0 {
+			age = 0
+		}
+		header.Set("Age", strconv.FormatInt(age, 10))
+	}
+	etag := entry.Header.Get("ETag")
+	if etag != "" && c.status[entry.Status] && etagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+//parseCacheControl 解析Cache-Control头,指令名统一为小写
+func parseCacheControl(value string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+//hasCredentials 请求是否带有身份信息
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+//sharedCacheable 响应是否明确允许被共享缓存用于带有身份信息的请求
+func sharedCacheable(directives map[string]string) bool {
+	for _, d := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := directives[d]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//responseVary 解析响应的Vary头,请求头名统一为规范形式并排序
+//@returns []string Vary的请求头列表
+//@returns bool 是否包含*
+func responseVary(header http.Header) ([]string, bool) {
+	names := map[string]bool{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, true
+			}
+			names[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	vary := make([]string, 0, len(names))
+	for name := range names {
+		vary = append(vary, name)
+	}
+	sort.Strings(vary)
+	return vary, false
+}
+
+//variantKey 按请求中Vary的请求头的值计算变体的缓存键
+func variantKey(key string, r *http.Request, vary []string) string {
+	var b strings.Builder
+	for _, name := range vary {
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+		b.WriteString("\n")
+	}
+	h := md5.New()
+	h.Write([]byte(b.String()))
+	return key + "::" + hex.EncodeToString(h.Sum(nil))
+}
+
+//etagMatch 按弱比较判断If-None-Match是否与ETag匹配
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+//responseRecorder 缓冲处理函数响应的http.ResponseWriter
+type responseRecorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_http_cache(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	c, err := NewHTTPCache(cli,
+		WithHTTPVaryHeaders("Accept-Language"),
+		WithHTTPQueryParams("page"),
+		WithHTTPCacheOptions(WithSpecifiedKey("test_http_cache"), WithMaxTTL(10*time.Second)),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new http cache error")
+	}
+	var count int64
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s-%d", r.Header.Get("Accept-Language"), n)
+	}))
+	do := func(method, target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	defer func() {
+		keys, _ := cli.Keys(ctx, "test_http_cache::*").Result()
+		if len(keys) > 0 {
+			cli.Del(ctx, keys...)
+		}
+	}()
+
+	w := do(http.MethodGet, "/a?page=1&other=x", map[string]string{"Accept-Language": "en"})
+	assert.Equal(t, "en-1", w.Body.String())
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	//未参与计算缓存键的查询参数不影响命中
+	w = do(http.MethodGet, "/a?other=y&page=1", map[string]string{"Accept-Language": "en"})
+	assert.Equal(t, "en-1", w.Body.String())
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	//参与计算缓存键的请求头不同则不命中
+	w = do(http.MethodGet, "/a?page=1", map[string]string{"Accept-Language": "zh"})
+	assert.Equal(t, "zh-2", w.Body.String())
+	//If-None-Match
+	w = do(http.MethodGet, "/a?page=1", map[string]string{"Accept-Language": "en", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, 0, w.Body.Len())
+	//请求no-cache时重新执行处理函数
+	w = do(http.MethodGet, "/a?page=1", map[string]string{"Accept-Language": "en", "Cache-Control": "no-cache"})
+	assert.Equal(t, "en-3", w.Body.String())
+	w = do(http.MethodGet, "/a?page=1", map[string]string{"Accept-Language": "en"})
+	assert.Equal(t, "en-3", w.Body.String())
+	//响应private时不缓存
+	do(http.MethodGet, "/private", nil)
+	w = do(http.MethodGet, "/private", nil)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, int64(5), atomic.LoadInt64(&count))
+	//非缓存方法直接执行
+	w = do(http.MethodPost, "/a?page=1", nil)
+	assert.Equal(t, "", w.Header().Get("X-Cache"))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&count))
+}
+
+func Test_http_cache_max_age(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	c, err := NewHTTPCache(cli, WithHTTPCacheOptions(WithSpecifiedKey("test_http_cache"), WithMaxTTL(10*time.Second)))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new http cache error")
+	}
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=2")
+		w.Write([]byte("ok"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/max_age", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	key := c.RequestKey(req)
+	defer cli.Del(ctx, key)
+	ttl, err := cli.TTL(ctx, key).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "ttl error")
+	}
+	assert.LessOrEqual(t, ttl, 2*time.Second)
+	assert.Greater(t, ttl, time.Duration(0))
+}
+
+func Test_http_cache_coalesce(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	var count int64
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&count, 1)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("ok"))
+	})
+	//模拟多个副本
+	handlers := []http.Handler{}
+	for i := 0; i < 3; i++ {
+		c, err := NewHTTPCache(cli, WithHTTPCoalesce(2*time.Second), WithHTTPCacheOptions(WithSpecifiedKey("test_http_cache"), WithMaxTTL(10*time.Second)))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "new http cache error")
+		}
+		handlers = append(handlers, c.Middleware(next))
+	}
+	defer func() {
+		keys, _ := cli.Keys(ctx, "test_http_cache::*").Result()
+		if len(keys) > 0 {
+			cli.Del(ctx, keys...)
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(h http.Handler) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/coalesce", nil))
+			assert.Equal(t, "ok", w.Body.String())
+		}(handlers[i%3])
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+}
+
+func Test_http_cache_vary_and_credentials(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	c, err := NewHTTPCache(cli, WithHTTPCacheOptions(WithSpecifiedKey("test_http_cache"), WithMaxTTL(10*time.Second)))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new http cache error")
+	}
+	var count int64
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&count, 1)
+		switch r.URL.Path {
+		case "/vary":
+			w.Header().Set("Vary", "Accept-Encoding")
+		case "/vary_any":
+			w.Header().Set("Vary", "*")
+		case "/public":
+			w.Header().Set("Cache-Control", "public")
+		}
+		fmt.Fprintf(w, "%s-%d", r.Header.Get("Accept-Encoding"), n)
+	}))
+	do := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	defer func() {
+		keys, _ := cli.Keys(ctx, "test_http_cache::*").Result()
+		if len(keys) > 0 {
+			cli.Del(ctx, keys...)
+		}
+	}()
+
+	//响应Vary的请求头参与计算缓存键
+	w := do("/vary", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, "gzip-1", w.Body.String())
+	w = do("/vary", map[string]string{"Accept-Encoding": "br"})
+	assert.Equal(t, "br-2", w.Body.String())
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	w = do("/vary", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, "gzip-1", w.Body.String())
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	//Vary: *不缓存
+	do("/vary_any", nil)
+	w = do("/vary_any", nil)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&count))
+
+	//带有身份信息的请求不读取也不写入普通响应的缓存
+	w = do("/private_data", nil)
+	assert.Equal(t, "-5", w.Body.String())
+	w = do("/private_data", map[string]string{"Authorization": "Bearer a"})
+	assert.Equal(t, "-6", w.Body.String())
+	w = do("/user", map[string]string{"Cookie": "session=a"})
+	assert.Equal(t, "-7", w.Body.String())
+	w = do("/user", map[string]string{"Cookie": "session=b"})
+	assert.Equal(t, "-8", w.Body.String())
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	//响应带有public时可以共享
+	w = do("/public", map[string]string{"Authorization": "Bearer a"})
+	assert.Equal(t, "-9", w.Body.String())
+	w = do("/public", map[string]string{"Authorization": "Bearer b"})
+	assert.Equal(t, "-9", w.Body.String())
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+}
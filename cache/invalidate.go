@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/scaner"
+	"github.com/go-redis/redis/v8"
+)
+
+//tagScript 将缓存的键记录到标签对应的集合中并维护集合的过期时间
+//集合的过期时间取其中成员最长的过期时间,有成员不过期时集合也不过期
+//KEYS[1]为标签对应的集合,ARGV[1]为缓存的键,ARGV[2]为缓存的过期时间(ms),为0表示不过期
+var tagScript = redis.NewScript(`
+local added = redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+	return 1
+end
+if added == 1 and redis.call("SCARD", KEYS[1]) == 1 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+	return 1
+end
+local cur = redis.call("PTTL", KEYS[1])
+if cur >= 0 and cur < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1`)
+
+//TagKey 标签对应的集合使用的键
+//@params tag string 标签
+func (o *Options) TagKey(tag string) string {
+	return o.TagPrefix + "::" + tag
+}
+
+//taggedKey 需要记录标签的缓存键
+type taggedKey struct {
+	key  string
+	ttl  int64 //缓存的过期时间(ms)
+	tags []string
+}
+
+//addTags 将缓存的键记录到标签对应的集合中
+//每个标签单独执行一次脚本,因此在集群中也可以使用;pipeline中EVALSHA失败时无法回退,因此直接使用EVAL
+func addTags(ctx context.Context, cli redis.UniversalClient, opt *Options, keys ...taggedKey) error {
+	count := 0
+	for _, k := range keys {
+		count += len(k.tags)
+	}
+	if count == 0 {
+		return nil
+	}
+	_, err := cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range keys {
+			for _, tag := range k.tags {
+				tagScript.Eval(ctx, pipe, []string{opt.TagKey(tag)}, k.key, k.ttl)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+//deleteKeys 分批删除键
+//每个键使用单独的DEL命令在pipeline中执行,避免集群中跨slot的问题
+func deleteKeys(ctx context.Context, cli redis.UniversalClient, opt *Options, keys []string) (int64, error) {
+	var count int64
+	batch := int(opt.InvalidateBatchSize)
+	if batch <= 0 {
+		batch = len(keys)
+	}
+	for start := 0; start < len(keys); start += batch {
+		end := start + batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		part := keys[start:end]
+		cmds := make([]*redis.IntCmd, 0, len(part))
+		_, err := cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, k := range part {
+				cmds = append(cmds, pipe.Del(ctx, k))
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		for _, cmd := range cmds {
+			count += cmd.Val()
+		}
+		if opt.Near != nil {
+			err := opt.Near.Invalidate(ctx, part...)
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+//InvalidateTag 删除带有标签的所有缓存
+//使用SSCAN分批遍历标签对应的集合并删除其中的键,最后删除集合本身
+//设置了近端缓存时同时通知近端缓存失效
+//@params cli redis.UniversalClient 缓存使用的redis客户端
+//@params tag string 标签
+//@params opts ...optparams.Option[Options] 与缓存一致的标签前缀,批大小和近端缓存设置
+//@returns int64 删除的缓存数
+func InvalidateTag(ctx context.Context, cli redis.UniversalClient, tag string, opts ...optparams.Option[Options]) (int64, error) {
+	opt := Defaultopt
+	optparams.GetOption(&opt, opts...)
+	tagKey := opt.TagKey(tag)
+	var count int64
+	var cursor uint64
+	for {
+		keys, next, err := cli.SScan(ctx, tagKey, cursor, "", opt.InvalidateBatchSize).Result()
+		if err != nil {
+			return count, err
+		}
+		n, err := deleteKeys(ctx, cli, &opt, keys)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	_, err := cli.Del(ctx, tagKey).Result()
+	return count, err
+}
+
+//InvalidatePattern 删除键满足模式的所有缓存
+//使用SCAN遍历满足模式的键,每取到一页就删除这一页,不会把所有键都加载到内存,集群客户端会遍历每个主节点
+//设置了近端缓存时同时通知近端缓存失效
+//@params cli redis.UniversalClient 缓存使用的redis客户端
+//@params pattern string 键的模式,与SCAN命令的MATCH参数一致
+//@params opts ...optparams.Option[Options] 与缓存一致的批大小和近端缓存设置
+//@returns int64 删除的缓存数
+func InvalidatePattern(ctx context.Context, cli redis.UniversalClient, pattern string, opts ...optparams.Option[Options]) (int64, error) {
+	opt := Defaultopt
+	optparams.GetOption(&opt, opts...)
+	invalidate := func(ctx context.Context, node redis.UniversalClient) (int64, error) {
+		var count int64
+		err := scaner.New(node, scaner.WithSetpSize(opt.InvalidateBatchSize)).ForEachPage(ctx, pattern, func(keys []string) error {
+			n, err := deleteKeys(ctx, cli, &opt, keys)
+			count += n
+			return err
+		})
+		return count, err
+	}
+	cluster, ok := cli.(*redis.ClusterClient)
+	if !ok {
+		return invalidate(ctx, cli)
+	}
+	var count int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+		n, err := invalidate(ctx, master)
+		atomic.AddInt64(&count, n)
+		return err
+	})
+	return count, err
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cache_invalidate_tag(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(10*time.Second), WithTags("user:42"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte("ok"), nil
+	})
+	cache.ActiveUpdate()
+
+	keyed, err := NewKeyed[int, string](cli, WithSpecifiedKey("test_keyed_cache"), WithMaxTTL(20*time.Second), WithInvalidateBatchSize(2))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	keyed.RegistTagFunc(func(key int, value string) []string {
+		return []string{fmt.Sprintf("user:%d", key)}
+	})
+	keyed.RegistLoader(func(ctx context.Context, keys []int) (map[int]string, error) {
+		res := map[int]string{}
+		for _, k := range keys {
+			res[k] = "value"
+		}
+		return res, nil
+	})
+	_, err = keyed.GetMany(ctx, []int{41, 42})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	ttl, err := cli.PTTL(ctx, Defaultopt.TagKey("user:42")).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "PTTL error")
+	}
+	assert.Greater(t, ttl, 10*time.Second)
+
+	count, err := InvalidateTag(ctx, cli, "user:42", WithInvalidateBatchSize(1))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "InvalidateTag error")
+	}
+	assert.Equal(t, int64(2), count)
+	exists, err := cli.Exists(ctx, "test_cache", keyed.EntryKey(42), Defaultopt.TagKey("user:42")).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Exists error")
+	}
+	assert.Equal(t, int64(0), exists)
+	exists, err = cli.Exists(ctx, keyed.EntryKey(41)).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Exists error")
+	}
+	assert.Equal(t, int64(1), exists)
+}
+
+func Test_cache_invalidate_pattern(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	keyed, err := NewKeyed[int, string](cli, WithSpecifiedKey("test_keyed_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	entries := map[int]string{}
+	for i := 0; i < 10; i++ {
+		entries[i] = "value"
+	}
+	err = keyed.SetMany(ctx, entries)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "SetMany error")
+	}
+	cli.Set(ctx, "other_key", "value", 0)
+	count, err := InvalidatePattern(ctx, cli, "test_keyed_cache::*", WithInvalidateBatchSize(3))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "InvalidatePattern error")
+	}
+	assert.Equal(t, int64(10), count)
+	exists, err := cli.Exists(ctx, "other_key").Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Exists error")
+	}
+	assert.Equal(t, int64(1), exists)
+}
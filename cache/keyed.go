@@ -0,0 +1,440 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//KeyedLoader 批量加载缓存未命中的条目
+//返回的map中不包含的键视为数据不存在,不会被写入缓存
+type KeyedLoader[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+//EntryTTLFunc 计算条目过期时间的函数,返回0表示不过期
+type EntryTTLFunc[K comparable, V any] func(key K, value V) time.Duration
+
+//TagFunc 计算条目标签的函数,返回的标签会和设置的Tags一起附加到条目上
+type TagFunc[K comparable, V any] func(key K, value V) []string
+
+//KeyedCache 多条目缓存
+//每个条目使用中间件的键作为前缀拼接条目的键作为redis中的键,值使用设置的编解码器序列化
+//未命中的条目会合并为一次调用交由注册的加载函数批量加载
+//开启负缓存时加载函数未返回的条目会以负缓存标识缓存NegativeTTL时长,设置了过滤器时过滤器判断一定不存在的条目不会交给加载函数
+type KeyedCache[K comparable, V any] struct {
+	*middlewarehelper.MiddleWareAbc
+	opt      Options
+	loader   KeyedLoader[K, V]
+	entryTTL EntryTTLFunc[K, V]
+	tagFunc  TagFunc[K, V]
+}
+
+//NewKeyed 创建一个多条目缓存实例
+//条目的默认过期时间为MaxTTL
+func NewKeyed[K comparable, V any](cli redis.UniversalClient, opts ...optparams.Option[Options]) (*KeyedCache[K, V], error) {
+	c := new(KeyedCache[K, V])
+	c.opt = Defaultopt
+	optparams.GetOption(&c.opt, opts...)
+	m, err := middlewarehelper.New(cli, "cache", c.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c.MiddleWareAbc = m
+	return c, nil
+}
+
+//RegistLoader 注册批量加载函数到对象
+//@params fn KeyedLoader[K, V] 批量加载函数
+func (c *KeyedCache[K, V]) RegistLoader(fn KeyedLoader[K, V]) error {
+	if c.loader != nil {
+		return ErrUpdateFuncAlreadyRegisted
+	}
+	c.loader = fn
+	return nil
+}
+
+//RegistEntryTTLFunc 注册计算条目过期时间的函数,未注册时条目使用MaxTTL作为过期时间
+//@params fn EntryTTLFunc[K, V] 计算条目过期时间的函数
+func (c *KeyedCache[K, V]) RegistEntryTTLFunc(fn EntryTTLFunc[K, V]) {
+	c.entryTTL = fn
+}
+
+//RegistTagFunc 注册计算条目标签的函数
+//@params fn TagFunc[K, V] 计算条目标签的函数
+func (c *KeyedCache[K, V]) RegistTagFunc(fn TagFunc[K, V]) {
+	c.tagFunc = fn
+}
+
+//tags 条目的标签
+func (c *KeyedCache[K, V]) tags(key K, value V) []string {
+	if c.tagFunc == nil {
+		return c.opt.Tags
+	}
+	return append(append([]string{}, c.opt.Tags...), c.tagFunc(key, value)...)
+}
+
+//EntryKey 条目在redis中使用的键
+//@params key K 条目的键
+func (c *KeyedCache[K, V]) EntryKey(key K) string {
+	return fmt.Sprintf("%s::%v", c.Key(), key)
+}
+
+//ttl 条目的过期时间
+func (c *KeyedCache[K, V]) ttl(key K, value V) time.Duration {
+	if c.entryTTL != nil {
+		return c.entryTTL(key, value)
+	}
+	return c.MaxTTL()
+}
+
+//isCluster 判断使用的客户端是否为集群客户端,集群客户端的MGET不能跨slot
+func (c *KeyedCache[K, V]) isCluster() bool {
+	_, ok := c.Client().(*redis.ClusterClient)
+	return ok
+}
+
+//条目数据的首字节标识条目类型,用于区分负缓存与编码结果为空的值
+const (
+	entryNegative byte = 0 //负缓存标识,条目数据只有这1个字节
+	entryValue    byte = 1 //值标识,之后为编码后的值
+)
+
+//negativeEntry 负缓存条目的数据
+var negativeEntry = string([]byte{entryNegative})
+
+//encodeEntry 编码值并加上值标识
+func (c *KeyedCache[K, V]) encodeEntry(value V) ([]byte, error) {
+	data, err := c.opt.encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{entryValue}, data...), nil
+}
+
+//mget 批量获取条目的原始数据,不存在的条目对应的值为nil
+//设置了近端缓存时优先从近端缓存获取,从redis获取到的数据会写入近端缓存
+func (c *KeyedCache[K, V]) mget(ctx context.Context, redisKeys []string) ([]interface{}, error) {
+	if c.opt.Near == nil {
+		return c.mgetRedis(ctx, redisKeys)
+	}
+	res := make([]interface{}, len(redisKeys))
+	missKeys := []string{}
+	missIndexes := []int{}
+	for i, k := range redisKeys {
+		if v, ok := c.opt.Near.get(k); ok {
+			res[i] = v
+			continue
+		}
+		missKeys = append(missKeys, k)
+		missIndexes = append(missIndexes, i)
+	}
+	if len(missKeys) == 0 {
+		return res, nil
+	}
+	values, err := c.mgetRedis(ctx, missKeys)
+	if err != nil {
+		return nil, err
+	}
+	for j, v := range values {
+		res[missIndexes[j]] = v
+		if s, ok := v.(string); ok {
+			c.opt.Near.set(missKeys[j], s)
+		}
+	}
+	return res, nil
+}
+
+//mgetRedis 从redis批量获取条目的原始数据,不存在的条目对应的值为nil
+//集群客户端使用pipeline执行GET以避免跨slot
+func (c *KeyedCache[K, V]) mgetRedis(ctx context.Context, redisKeys []string) ([]interface{}, error) {
+	if !c.isCluster() {
+		return c.Client().MGet(ctx, redisKeys...).Result()
+	}
+	cmds := make([]*redis.StringCmd, 0, len(redisKeys))
+	_, err := c.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range redisKeys {
+			cmds = append(cmds, pipe.Get(ctx, k))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	res := make([]interface{}, 0, len(cmds))
+	for _, cmd := range cmds {
+		v, err := cmd.Result()
+		if err != nil {
+			if err == redis.Nil {
+				res = append(res, nil)
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// 写操作
+
+//Set 将条目写入缓存
+//@params key K 条目的键
+//@params value V 条目的值
+func (c *KeyedCache[K, V]) Set(ctx context.Context, key K, value V) error {
+	return c.SetMany(ctx, map[K]V{key: value})
+}
+
+//SetMany 将多个条目写入缓存,设置了过滤器时同时将条目记录到过滤器
+//@params entries map[K]V 待写入的条目
+func (c *KeyedCache[K, V]) SetMany(ctx context.Context, entries map[K]V) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	pipefn := func(pipe redis.Pipeliner) error {
+		for k, v := range entries {
+			data, err := c.encodeEntry(v)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, c.EntryKey(k), data, c.ttl(k, v))
+		}
+		return nil
+	}
+	var err error
+	if c.isCluster() {
+		_, err = c.Client().Pipelined(ctx, pipefn)
+	} else {
+		_, err = c.Client().TxPipelined(ctx, pipefn)
+	}
+	if err != nil {
+		return err
+	}
+	redisKeys := make([]string, 0, len(entries))
+	items := make([]string, 0, len(entries))
+	for k := range entries {
+		redisKeys = append(redisKeys, c.EntryKey(k))
+		items = append(items, fmt.Sprint(k))
+	}
+	c.invalidateNear(ctx, redisKeys)
+	tagged := make([]taggedKey, 0, len(entries))
+	for k, v := range entries {
+		tagged = append(tagged, taggedKey{key: c.EntryKey(k), ttl: c.ttl(k, v).Milliseconds(), tags: c.tags(k, v)})
+	}
+	err = addTags(ctx, c.Client(), &c.opt, tagged...)
+	if err != nil {
+		c.Logger().Warn("add cache tags get error", map[string]any{"err": err.Error()})
+	}
+	if c.opt.Filter != nil {
+		err := c.opt.Filter.Add(ctx, items...)
+		if err != nil {
+			c.Logger().Warn("add to existence filter get error", map[string]any{"err": err.Error()})
+		}
+	}
+	return nil
+}
+
+//invalidateNear 通知近端缓存条目已失效
+func (c *KeyedCache[K, V]) invalidateNear(ctx context.Context, redisKeys []string) {
+	if c.opt.Near == nil {
+		return
+	}
+	err := c.opt.Near.Invalidate(ctx, redisKeys...)
+	if err != nil {
+		c.Logger().Warn("invalidate near cache get error", map[string]any{"err": err.Error()})
+	}
+}
+
+//Invalidate 删除缓存中的条目
+//@params keys ...K 要删除的条目的键
+func (c *KeyedCache[K, V]) Invalidate(ctx context.Context, keys ...K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		redisKeys = append(redisKeys, c.EntryKey(k))
+	}
+	_, err := c.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range redisKeys {
+			pipe.Del(ctx, k)
+		}
+		return nil
+	})
+	c.invalidateNear(ctx, redisKeys)
+	return err
+}
+
+// 读操作
+
+//Get 获取单个条目
+//缓存未命中时调用加载函数加载,加载函数也没有返回该条目时返回ErrEntryNotExists
+//@params key K 条目的键
+func (c *KeyedCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	res, err := c.GetMany(ctx, []K{key})
+	if err != nil {
+		var v V
+		return v, err
+	}
+	v, ok := res[key]
+	if !ok {
+		return v, ErrEntryNotExists
+	}
+	return v, nil
+}
+
+//GetMany 批量获取条目
+//先使用MGET从缓存中获取,未命中的条目合并为一次加载函数调用,加载得到的结果写入缓存
+//返回的map中不包含不存在的条目
+//@params keys []K 条目的键
+func (c *KeyedCache[K, V]) GetMany(ctx context.Context, keys []K) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		redisKeys = append(redisKeys, c.EntryKey(k))
+	}
+	misses := []K{}
+	values, err := c.mget(ctx, redisKeys)
+	if err != nil {
+		c.Logger().Warn("mget cache get error", map[string]any{"err": err.Error()})
+		misses = keys
+	} else {
+		for i, raw := range values {
+			s, ok := raw.(string)
+			if !ok {
+				misses = append(misses, keys[i])
+				continue
+			}
+			//负缓存
+			if s == negativeEntry {
+				continue
+			}
+			if len(s) == 0 || s[0] != entryValue {
+				c.Logger().Warn("unknown cache entry format", map[string]any{"entry": redisKeys[i]})
+				misses = append(misses, keys[i])
+				continue
+			}
+			v, err := decodeTo[V](&c.opt, []byte(s[1:]))
+			if err != nil {
+				c.Logger().Warn("decode cache entry get error", map[string]any{"err": err.Error(), "entry": redisKeys[i]})
+				misses = append(misses, keys[i])
+				continue
+			}
+			result[keys[i]] = v
+		}
+	}
+	misses = c.filter(ctx, misses)
+	if len(misses) == 0 {
+		return result, nil
+	}
+	loaded, err := c.load(ctx, misses)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range loaded {
+		result[k] = v
+	}
+	return result, nil
+}
+
+//load 调用加载函数加载未命中的条目并写入缓存
+func (c *KeyedCache[K, V]) load(ctx context.Context, keys []K) (map[K]V, error) {
+	if c.loader == nil {
+		return nil, ErrLoaderNotRegisted
+	}
+	if c.opt.Limiter != nil {
+		lctx, cancel := context.WithTimeout(ctx, c.opt.QueryLimiterTimeout)
+		defer cancel()
+		canflood, err := c.opt.Limiter.Flood(lctx, 1)
+		if err != nil {
+			c.Logger().Error("cache's limiter get error", map[string]any{"err": err.Error()})
+		} else if !canflood {
+			return nil, ErrLimiterNotAllow
+		}
+	}
+	loaded, err := c.loader(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), c.opt.QueryAutoUpdateCacheTimeout)
+	defer cancel()
+	if len(loaded) > 0 {
+		err := c.SetMany(sctx, loaded)
+		if err != nil {
+			c.Logger().Warn("set cache entries get error", map[string]any{"err": err.Error()})
+		}
+	}
+	if c.opt.NegativeTTL > 0 && len(loaded) < len(keys) {
+		notExists := make([]K, 0, len(keys)-len(loaded))
+		for _, k := range keys {
+			if _, ok := loaded[k]; !ok {
+				notExists = append(notExists, k)
+			}
+		}
+		err := c.setNegative(sctx, notExists)
+		if err != nil {
+			c.Logger().Warn("set negative cache entries get error", map[string]any{"err": err.Error()})
+		}
+	}
+	return loaded, nil
+}
+
+//filter 使用过滤器去掉一定不存在的条目,过滤器出错或还没有写入所有存在的元素时不过滤
+func (c *KeyedCache[K, V]) filter(ctx context.Context, keys []K) []K {
+	if c.opt.Filter == nil || len(keys) == 0 {
+		return keys
+	}
+	if f, ok := c.opt.Filter.(SeededFilter); ok {
+		seeded, err := f.Seeded(ctx)
+		if err != nil {
+			c.Logger().Warn("existence filter get error", map[string]any{"err": err.Error()})
+			return keys
+		}
+		if !seeded {
+			return keys
+		}
+	}
+	items := make([]string, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, fmt.Sprint(k))
+	}
+	exists, err := c.opt.Filter.MightContain(ctx, items...)
+	if err != nil {
+		c.Logger().Warn("existence filter get error", map[string]any{"err": err.Error()})
+		return keys
+	}
+	res := make([]K, 0, len(keys))
+	for i, k := range keys {
+		if exists[items[i]] {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+//setNegative 将不存在的条目以负缓存标识写入缓存,过期时间为NegativeTTL
+func (c *KeyedCache[K, V]) setNegative(ctx context.Context, keys []K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		redisKeys = append(redisKeys, c.EntryKey(k))
+	}
+	_, err := c.Client().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range redisKeys {
+			pipe.Set(ctx, k, negativeEntry, c.opt.NegativeTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.invalidateNear(ctx, redisKeys)
+	return nil
+}
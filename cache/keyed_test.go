@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_keyed_cache(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := NewKeyed[int64, testUser](cli, WithSpecifiedKey("test_keyed_cache"), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	_, err = cache.Get(ctx, 1)
+	assert.Equal(t, ErrLoaderNotRegisted, err)
+
+	calls := [][]int64{}
+	cache.RegistLoader(func(ctx context.Context, keys []int64) (map[int64]testUser, error) {
+		calls = append(calls, keys)
+		res := map[int64]testUser{}
+		for _, k := range keys {
+			if k > 0 {
+				res[k] = testUser{ID: k, Name: "user"}
+			}
+		}
+		return res, nil
+	})
+	cache.RegistEntryTTLFunc(func(key int64, value testUser) time.Duration {
+		return time.Duration(key) * time.Minute
+	})
+	assert.Equal(t, "test_keyed_cache::2", cache.EntryKey(2))
+
+	res, err := cache.GetMany(ctx, []int64{1, 2, -1})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, 2, len(res))
+	assert.Equal(t, testUser{ID: 2, Name: "user"}, res[2])
+	ttl, err := cli.TTL(ctx, cache.EntryKey(2)).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "TTL error")
+	}
+	assert.Greater(t, ttl, time.Minute)
+
+	res, err = cache.GetMany(ctx, []int64{1, 2, 3})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, 3, len(res))
+	assert.Equal(t, 2, len(calls))
+	sort.Slice(calls[1], func(i, j int) bool { return calls[1][i] < calls[1][j] })
+	assert.Equal(t, []int64{3}, calls[1])
+
+	_, err = cache.Get(ctx, -1)
+	assert.Equal(t, ErrEntryNotExists, err)
+
+	err = cache.Invalidate(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Invalidate error")
+	}
+	u, err := cache.Get(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "Get error")
+	}
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, []int64{1}, calls[len(calls)-1])
+}
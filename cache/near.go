@@ -0,0 +1,339 @@
+package cache
+
+import (
+	"context"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/pchelper"
+	"github.com/Golang-Tools/redishelper/v2/pubsubhelper"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//InvalidateModeType 近端缓存保持一致的方式
+type InvalidateModeType uint16
+
+const (
+
+	//InvalidateMode__PUBSUB 写入缓存的一方通过pubsub频道广播失效的键
+	InvalidateMode__PUBSUB InvalidateModeType = iota
+	//InvalidateMode__TRACKING 使用redis6的CLIENT TRACKING广播模式,由redis推送失效的键
+	InvalidateMode__TRACKING
+)
+
+//trackingChannel redis推送失效消息使用的频道
+const trackingChannel = "__redis__:invalidate"
+
+//NearOptions 近端缓存的配置
+type NearOptions struct {
+	Capacity         int                //本地缓存的最大条目数,为0时不限制
+	TTL              time.Duration      //本地缓存条目的过期时间,为0时只依赖失效消息
+	EvictPolicy      EvictPolicyType    //本地缓存满时的淘汰策略
+	InvalidateMode   InvalidateModeType //保持一致的方式
+	Channel          string             //pubsub模式下广播失效消息的频道
+	TrackingPrefixes []string           //tracking模式下监听的键前缀,为空时监听所有键
+}
+
+//defaultNearOptions 近端缓存的默认配置
+var defaultNearOptions = NearOptions{
+	Capacity:       1024,
+	TTL:            time.Minute,
+	EvictPolicy:    EvictPolicy__LRU,
+	InvalidateMode: InvalidateMode__PUBSUB,
+	Channel:        "redishelper::cache::invalidate",
+}
+
+//WithNearCapacity 设置近端缓存的最大条目数
+func WithNearCapacity(capacity int) optparams.Option[NearOptions] {
+	return optparams.NewFuncOption(func(o *NearOptions) {
+		o.Capacity = capacity
+	})
+}
+
+//WithNearTTL 设置近端缓存条目的过期时间
+func WithNearTTL(ttl time.Duration) optparams.Option[NearOptions] {
+	return optparams.NewFuncOption(func(o *NearOptions) {
+		o.TTL = ttl
+	})
+}
+
+//WithNearLFU 近端缓存使用LFU淘汰策略,默认为LRU
+func WithNearLFU() optparams.Option[NearOptions] {
+	return optparams.NewFuncOption(func(o *NearOptions) {
+		o.EvictPolicy = EvictPolicy__LFU
+	})
+}
+
+//WithNearChannel 设置pubsub模式下广播失效消息的频道
+func WithNearChannel(channel string) optparams.Option[NearOptions] {
+	return optparams.NewFuncOption(func(o *NearOptions) {
+		o.InvalidateMode = InvalidateMode__PUBSUB
+		o.Channel = channel
+	})
+}
+
+//WithNearClientTracking 使用CLIENT TRACKING的广播模式保持一致,需要redis 6以上且只支持单机客户端
+//@params prefixes ...string 监听的键前缀,不设置时监听所有键
+func WithNearClientTracking(prefixes ...string) optparams.Option[NearOptions] {
+	return optparams.NewFuncOption(func(o *NearOptions) {
+		o.InvalidateMode = InvalidateMode__TRACKING
+		o.TrackingPrefixes = prefixes
+	})
+}
+
+//NearStats 近端缓存的统计信息
+type NearStats struct {
+	Hits          int64 //命中次数
+	Misses        int64 //未命中次数
+	Evictions     int64 //因容量被淘汰的条目数
+	Invalidations int64 //因失效消息被删除的条目数
+}
+
+//HitRate 命中率
+func (s NearStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+//Near 近端缓存
+//在进程内维护一份有容量上限和过期时间的本地缓存放在redis之前,通过pubsub或CLIENT TRACKING接收失效消息保持一致
+//同一个Near可以被多个Cache和KeyedCache共用
+//注意失效消息是异步到达的,本地缓存可能短暂地返回旧数据,最长不超过设置的TTL
+type Near struct {
+	cli           redis.UniversalClient
+	opt           NearOptions
+	store         *nearStore
+	hits          int64
+	misses        int64
+	evictions     int64
+	invalidations int64
+	closed        int32
+	producer      *pubsubhelper.Producer
+	consumer      *pubsubhelper.Consumer
+	trackingCli   *redis.Client
+	trackingSub   *redis.PubSub
+	logger        *log.Log
+}
+
+//NewNear 创建一个近端缓存并开始监听失效消息
+//@params cli redis.UniversalClient 缓存使用的redis客户端
+func NewNear(cli redis.UniversalClient, opts ...optparams.Option[NearOptions]) (*Near, error) {
+	n := new(Near)
+	n.cli = cli
+	n.opt = defaultNearOptions
+	optparams.GetOption(&n.opt, opts...)
+	n.store = newNearStore(n.opt.Capacity, n.opt.TTL, n.opt.EvictPolicy)
+	log.Set(log.WithExtFields(log.Dict{"module": "redishelper", "middleware_type": "near_cache"}))
+	n.logger = log.Export()
+	log.Set(log.WithExtFields(log.Dict{}))
+	switch n.opt.InvalidateMode {
+	case InvalidateMode__TRACKING:
+		err := n.startTracking()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		err := n.startPubSub()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+//startPubSub 使用pubsubhelper监听失效消息
+func (n *Near) startPubSub() error {
+	//每个近端缓存使用独立的id,用于忽略自己发出的失效消息
+	producer, err := pubsubhelper.NewProducer(n.cli, pubsubhelper.SerializeWithJSON(), pubsubhelper.WithClientID(hex.EncodeToString(uuid.NewV4().Bytes())))
+	if err != nil {
+		return err
+	}
+	consumer, err := pubsubhelper.NewConsumer(n.cli, pubsubhelper.SerializeWithJSON())
+	if err != nil {
+		return err
+	}
+	consumer.RegistHandler(n.opt.Channel, func(evt *pchelper.Event) error {
+		//自己发出的失效消息在本地已经处理过了
+		if evt.Sender == producer.ClientID() {
+			return nil
+		}
+		keys, ok := evt.Payload.([]interface{})
+		if !ok {
+			n.Purge()
+			return nil
+		}
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				n.invalidateLocal(key)
+			}
+		}
+		return nil
+	})
+	n.producer = producer
+	n.consumer = consumer
+	go func() {
+		err := consumer.Listen(n.opt.Channel)
+		if err != nil {
+			n.logger.Error("near cache listen get error", log.Dict{"err": err.Error()})
+		}
+	}()
+	return nil
+}
+
+//startTracking 使用CLIENT TRACKING的广播模式监听失效消息
+//使用一个独立的连接订阅失效频道,并在每次建立连接时对该连接开启重定向到自身的tracking
+func (n *Near) startTracking() error {
+	c, ok := n.cli.(*redis.Client)
+	if !ok {
+		return ErrTrackingNeedSingleClient
+	}
+	opt := *c.Options()
+	onConnect := opt.OnConnect
+	opt.PoolSize = 1
+	opt.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if onConnect != nil {
+			err := onConnect(ctx, cn)
+			if err != nil {
+				return err
+			}
+		}
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		args := []interface{}{"CLIENT", "TRACKING", "on", "REDIRECT", id, "BCAST"}
+		for _, prefix := range n.opt.TrackingPrefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+		//重连后之前的失效消息可能已经丢失
+		n.Purge()
+		cmd := redis.NewCmd(ctx, args...)
+		err = cn.Process(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		return cmd.Err()
+	}
+	n.trackingCli = redis.NewClient(&opt)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	n.trackingSub = n.trackingCli.Subscribe(ctx, trackingChannel)
+	_, err := n.trackingSub.Receive(ctx)
+	if err != nil {
+		n.trackingSub.Close()
+		n.trackingCli.Close()
+		return err
+	}
+	go n.listenTracking()
+	return nil
+}
+
+//listenTracking 处理redis推送的失效消息
+func (n *Near) listenTracking() {
+	ctx := context.Background()
+	for {
+		msg, err := n.trackingSub.Receive(ctx)
+		if err != nil {
+			if atomic.LoadInt32(&n.closed) == 1 {
+				return
+			}
+			//FLUSHALL等操作的消息负载为空,无法判断失效的键
+			n.Purge()
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		m, ok := msg.(*redis.Message)
+		if !ok || m.Channel != trackingChannel {
+			continue
+		}
+		if m.Payload != "" {
+			n.invalidateLocal(m.Payload)
+		}
+		n.invalidateLocal(m.PayloadSlice...)
+	}
+}
+
+//get 从本地缓存获取数据
+func (n *Near) get(key string) (string, bool) {
+	v, ok := n.store.get(key)
+	if ok {
+		atomic.AddInt64(&n.hits, 1)
+	} else {
+		atomic.AddInt64(&n.misses, 1)
+	}
+	return v, ok
+}
+
+//set 将从redis中获取的数据写入本地缓存
+func (n *Near) set(key, value string) {
+	if n.store.set(key, value) {
+		atomic.AddInt64(&n.evictions, 1)
+	}
+}
+
+//invalidateLocal 删除本地缓存中的条目
+func (n *Near) invalidateLocal(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	count := n.store.del(keys...)
+	atomic.AddInt64(&n.invalidations, int64(count))
+}
+
+//Invalidate 删除本地缓存中的条目并通知其他进程
+//tracking模式下由redis在键被修改时推送失效消息,因此只删除本地的条目
+//@params keys ...string 失效的redis键
+func (n *Near) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	n.invalidateLocal(keys...)
+	if n.producer == nil {
+		return nil
+	}
+	_, err := n.producer.PubEvent(ctx, n.opt.Channel, keys)
+	return err
+}
+
+//Purge 清空本地缓存
+func (n *Near) Purge() {
+	n.store.purge()
+}
+
+//Len 本地缓存的条目数
+func (n *Near) Len() int {
+	return n.store.len()
+}
+
+//Stats 获取统计信息
+func (n *Near) Stats() NearStats {
+	return NearStats{
+		Hits:          atomic.LoadInt64(&n.hits),
+		Misses:        atomic.LoadInt64(&n.misses),
+		Evictions:     atomic.LoadInt64(&n.evictions),
+		Invalidations: atomic.LoadInt64(&n.invalidations),
+	}
+}
+
+//Close 停止监听失效消息并清空本地缓存
+func (n *Near) Close() error {
+	if !atomic.CompareAndSwapInt32(&n.closed, 0, 1) {
+		return nil
+	}
+	defer n.Purge()
+	if n.consumer != nil {
+		return n.consumer.StopListening()
+	}
+	if n.trackingSub != nil {
+		n.trackingSub.Close()
+		return n.trackingCli.Close()
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_nearstore_lru(t *testing.T) {
+	s := newNearStore(2, 0, EvictPolicy__LRU)
+	s.set("a", "1")
+	s.set("b", "2")
+	_, ok := s.get("a")
+	assert.True(t, ok)
+	assert.True(t, s.set("c", "3"))
+	_, ok = s.get("b")
+	assert.False(t, ok)
+	_, ok = s.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, s.del("a", "b"))
+	assert.Equal(t, 1, s.len())
+}
+
+func Test_nearstore_lfu(t *testing.T) {
+	s := newNearStore(2, 0, EvictPolicy__LFU)
+	s.set("a", "1")
+	s.set("b", "2")
+	s.get("b")
+	s.get("b")
+	s.get("a")
+	assert.True(t, s.set("c", "3"))
+	_, ok := s.get("a")
+	assert.False(t, ok)
+	v, ok := s.get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	s.purge()
+	assert.Equal(t, 0, s.len())
+}
+
+func Test_nearstore_ttl(t *testing.T) {
+	s := newNearStore(0, 50*time.Millisecond, EvictPolicy__LRU)
+	s.set("a", "1")
+	_, ok := s.get("a")
+	assert.True(t, ok)
+	time.Sleep(60 * time.Millisecond)
+	_, ok = s.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, s.len())
+}
+
+func Test_near_cache_pubsub(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	near1, err := NewNear(cli, WithNearChannel("test_near_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new near error")
+	}
+	defer near1.Close()
+	near2, err := NewNear(cli, WithNearChannel("test_near_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new near error")
+	}
+	defer near2.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	var count int64
+	fn := func() ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", atomic.AddInt64(&count, 1))), nil
+	}
+	cache1, err := New(cli, WithSpecifiedKey("test_cache"), WithNear(near1))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache1.RegistUpdateFunc(fn)
+	cache2, err := New(cli, WithSpecifiedKey("test_cache"), WithNear(near2))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache2.RegistUpdateFunc(fn)
+
+	res, err := cache1.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		res, err = cache1.Get(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "get error")
+		}
+		assert.Equal(t, "v1", string(res))
+	}
+	stats := near1.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, 1, near1.Len())
+
+	//另一个进程更新缓存后本地缓存失效
+	cache2.ActiveUpdate()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, near1.Len())
+	res, err = cache1.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v2", string(res))
+	assert.Equal(t, int64(1), near1.Stats().Invalidations)
+}
+
+func Test_near_keyed_cache(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	near, err := NewNear(cli, WithNearCapacity(10), WithNearLFU())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new near error")
+	}
+	defer near.Close()
+	cache, err := NewKeyed[string, string](cli, WithSpecifiedKey("test_keyed_cache"), WithNear(near))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistLoader(func(ctx context.Context, keys []string) (map[string]string, error) {
+		res := map[string]string{}
+		for _, k := range keys {
+			res[k] = "value_" + k
+		}
+		return res, nil
+	})
+	_, err = cache.GetMany(ctx, []string{"a", "b"})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	res, err := cache.GetMany(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, "value_c", res["c"])
+	res, err = cache.GetMany(ctx, []string{"a", "b", "c"})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "GetMany error")
+	}
+	assert.Equal(t, 3, len(res))
+	stats := near.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(6), stats.Misses)
+	assert.InDelta(t, 0.25, stats.HitRate(), 0.0001)
+}
+
+func Test_near_cache_tracking(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	near, err := NewNear(cli, WithNearClientTracking("test_cache"))
+	if err != nil {
+		t.Skip("redis not support client tracking: " + err.Error())
+	}
+	defer near.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithNear(near))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cli.Set(ctx, "test_cache", "v1", 0)
+	res, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	assert.Equal(t, 1, near.Len())
+	cli.Set(ctx, "test_cache", "v2", 0)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, near.Len())
+}
+
+//cmdCountHook 统计执行的命令
+type cmdCountHook struct {
+	lock  sync.Mutex
+	count map[string]int
+}
+
+func (h *cmdCountHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.count[cmd.Name()]++
+	return ctx, nil
+}
+
+func (h *cmdCountHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *cmdCountHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *cmdCountHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func (h *cmdCountHook) reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.count = map[string]int{}
+}
+
+func (h *cmdCountHook) get(name string) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.count[name]
+}
+
+func Test_near_cache_hit_skip_refresh_ttl(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	hook := &cmdCountHook{count: map[string]int{}}
+	cli.AddHook(hook)
+	near, err := NewNear(cli, WithNearChannel("test_near_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new near error")
+	}
+	defer near.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithNear(near), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte("ok"), nil
+	})
+	_, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	time.Sleep(100 * time.Millisecond)
+	hook.reset()
+	//从redis读取时刷新过期时间,使用已经取消的ctx也能刷新
+	near.Invalidate(ctx, cache.Key())
+	ctx1, cancel := context.WithCancel(ctx)
+	_, err = cache.Get(ctx1)
+	cancel()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, hook.get("get"))
+	assert.Equal(t, 1, hook.get("expire"))
+	//近端缓存命中时不访问redis
+	for i := 0; i < 3; i++ {
+		_, err = cache.Get(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "get error")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, hook.get("get"))
+	assert.Equal(t, 1, hook.get("expire"))
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+)
+
+//EvictPolicyType 本地缓存满时的淘汰策略
+type EvictPolicyType uint16
+
+const (
+
+	//EvictPolicy__LRU 淘汰最久未被访问的条目
+	EvictPolicy__LRU EvictPolicyType = iota
+	//EvictPolicy__LFU 淘汰访问次数最少的条目,次数相同时淘汰最久未被访问的条目
+	EvictPolicy__LFU
+)
+
+//nearEntry 本地缓存中的条目
+type nearEntry struct {
+	key        string
+	value      string
+	expireAt   time.Time
+	freq       int64
+	lastAccess int64
+	index      int           //LFU模式下在堆中的位置
+	elem       *list.Element //LRU模式下在链表中的位置
+}
+
+//lfuHeap 按访问次数排序的最小堆
+type lfuHeap []*nearEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].lastAccess < h[j].lastAccess
+	}
+	return h[i].freq < h[j].freq
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x any) {
+	e := x.(*nearEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+//nearStore 有容量上限和过期时间的本地缓存
+type nearStore struct {
+	lock     sync.Mutex
+	capacity int
+	ttl      time.Duration
+	policy   EvictPolicyType
+	entries  map[string]*nearEntry
+	lru      *list.List
+	lfu      lfuHeap
+	clock    int64
+}
+
+func newNearStore(capacity int, ttl time.Duration, policy EvictPolicyType) *nearStore {
+	return &nearStore{
+		capacity: capacity,
+		ttl:      ttl,
+		policy:   policy,
+		entries:  map[string]*nearEntry{},
+		lru:      list.New(),
+		lfu:      lfuHeap{},
+	}
+}
+
+//touch 记录一次访问
+func (s *nearStore) touch(e *nearEntry) {
+	s.clock++
+	e.lastAccess = s.clock
+	e.freq++
+	switch s.policy {
+	case EvictPolicy__LFU:
+		heap.Fix(&s.lfu, e.index)
+	default:
+		s.lru.MoveToFront(e.elem)
+	}
+}
+
+//remove 删除条目
+func (s *nearStore) remove(e *nearEntry) {
+	delete(s.entries, e.key)
+	switch s.policy {
+	case EvictPolicy__LFU:
+		heap.Remove(&s.lfu, e.index)
+	default:
+		s.lru.Remove(e.elem)
+	}
+}
+
+//evict 按淘汰策略淘汰一个条目
+func (s *nearStore) evict() {
+	switch s.policy {
+	case EvictPolicy__LFU:
+		if len(s.lfu) > 0 {
+			s.remove(s.lfu[0])
+		}
+	default:
+		if back := s.lru.Back(); back != nil {
+			s.remove(back.Value.(*nearEntry))
+		}
+	}
+}
+
+//get 获取条目,条目过期时会被删除
+func (s *nearStore) get(key string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !e.expireAt.IsZero() && !time.Now().Before(e.expireAt) {
+		s.remove(e)
+		return "", false
+	}
+	s.touch(e)
+	return e.value, true
+}
+
+//set 写入条目,超过容量时按淘汰策略淘汰
+//@returns bool 是否有条目被淘汰
+func (s *nearStore) set(key, value string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var expireAt time.Time
+	if s.ttl > 0 {
+		expireAt = time.Now().Add(s.ttl)
+	}
+	if e, ok := s.entries[key]; ok {
+		e.value = value
+		e.expireAt = expireAt
+		s.touch(e)
+		return false
+	}
+	evicted := false
+	if s.capacity > 0 && len(s.entries) >= s.capacity {
+		s.evict()
+		evicted = true
+	}
+	s.clock++
+	e := &nearEntry{key: key, value: value, expireAt: expireAt, freq: 1, lastAccess: s.clock}
+	switch s.policy {
+	case EvictPolicy__LFU:
+		heap.Push(&s.lfu, e)
+	default:
+		e.elem = s.lru.PushFront(e)
+	}
+	s.entries[key] = e
+	return evicted
+}
+
+//del 删除条目
+//@returns int 实际删除的条目数
+func (s *nearStore) del(keys ...string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, key := range keys {
+		if e, ok := s.entries[key]; ok {
+			s.remove(e)
+			count++
+		}
+	}
+	return count
+}
+
+//purge 清空所有条目
+func (s *nearStore) purge() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries = map[string]*nearEntry{}
+	s.lru.Init()
+	s.lfu = lfuHeap{}
+}
+
+//len 条目数量
+func (s *nearStore) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.entries)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
 	"github.com/Golang-Tools/redishelper/v2/lock"
 	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/Golang-Tools/redishelper/v2/pchelper"
 	"github.com/robfig/cron/v3"
 )
 
@@ -34,6 +35,8 @@ const (
 	EmptyResCacheMode__DELETE
 	//EmptyResCacheMode__SAVE 当更新函数得到的结果为空时依然存入作为缓存
 	EmptyResCacheMode__SAVE
+	//EmptyResCacheMode__NEGATIVE 当更新函数得到的结果为空时作为负缓存存入,过期时间使用NegativeTTL
+	EmptyResCacheMode__NEGATIVE
 )
 
 //Options broker的配置
@@ -42,10 +45,22 @@ type Options struct {
 	QueryAutoUpdateCacheTimeout time.Duration                                //自动更新时写入缓存的超时时长
 	Lock                        lock.LockInterface                           //使用的锁
 	QueryLockTimeout            time.Duration                                //请求锁的超时时间
+	WaitLockTimeout             time.Duration                                //锁被占用时等待持有者释放锁的最长时间,为0时不等待
 	Limiter                     limiterhelper.LimiterInterface               //使用的限制器
 	QueryLimiterTimeout         time.Duration                                //请求限流器的超时时间
 	EmptyResCacheMode           EmptyResCacheModeType                        //处理更新函数返回空值的模式
 	AlwaysRefreshTTL            bool                                         //是否即便没有变化也刷新TTL
+	Codec                       Codec                                        //Typed缓存使用的编解码器
+	Compressor                  Compressor                                   //Typed缓存使用的压缩器,为nil时不压缩
+	CompressThreshold           int                                          //序列化后的数据超过该字节数才压缩
+	SoftTTL                     time.Duration                                //软过期时间,超过后依然返回旧数据同时在后台重新计算,需要小于MaxTTL
+	XFetchBeta                  float64                                      //XFetch提前过期算法的beta参数,大于0时开启,越大越倾向于提前重新计算
+	Near                        *Near                                        //放在redis之前的近端缓存
+	NegativeTTL                 time.Duration                                //负缓存的过期时间
+	Filter                      ExistenceFilter                              //KeyedCache调用加载函数前用于过滤一定不存在的键的过滤器
+	Tags                        []string                                     //写入缓存时附加的标签
+	TagPrefix                   string                                       //标签对应的集合使用的键前缀
+	InvalidateBatchSize         int64                                        //批量失效时每批处理的键数
 	MiddlewareOpts              []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
 }
 
@@ -55,6 +70,9 @@ var Defaultopt = Options{
 	QueryAutoUpdateCacheTimeout: 300 * time.Millisecond,
 	QueryLockTimeout:            300 * time.Millisecond,
 	QueryLimiterTimeout:         300 * time.Millisecond,
+	Codec:                       JSONCodec{},
+	TagPrefix:                   "redishelper::cache::tag",
+	InvalidateBatchSize:         100,
 	MiddlewareOpts:              []optparams.Option[middlewarehelper.Options]{},
 }
 
@@ -79,6 +97,13 @@ func WithLockTimeout(timeout time.Duration) optparams.Option[Options] {
 	})
 }
 
+//WithWaitLock 设置锁被占用时等待持有者释放锁的最长时间,不设置时锁被占用会直接放弃
+func WithWaitLock(timeout time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.WaitLockTimeout = timeout
+	})
+}
+
 //WithLimiter 设置分布式限制器,限制器的作用是设置一段时间内的最大更新次数
 func WithLimiter(limiter limiterhelper.LimiterInterface) optparams.Option[Options] {
 	return optparams.NewFuncOption(func(o *Options) {
@@ -107,6 +132,98 @@ func WithAlwaysRefreshTTL() optparams.Option[Options] {
 	})
 }
 
+//WithCodec 设置Typed缓存使用的编解码器,默认使用JSONCodec
+func WithCodec(codec Codec) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Codec = codec
+	})
+}
+
+//WithSerialize 使用pchelper的序列化设置指定Typed缓存使用的编解码器,例如WithSerialize(pchelper.SerializeWithMsgpack())
+func WithSerialize(opts ...optparams.Option[pchelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		popt := pchelper.Defaultopt
+		optparams.GetOption(&popt, opts...)
+		codec, err := CodecFromSerializeProtocol(popt.SerializeProtocol)
+		if err == nil {
+			o.Codec = codec
+		}
+	})
+}
+
+//WithCompression 设置Typed缓存使用的压缩器,序列化后的数据超过threshold字节才会被压缩
+//设置压缩器后数据头部会写入1字节的压缩标识
+func WithCompression(compressor Compressor, threshold int) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Compressor = compressor
+		o.CompressThreshold = threshold
+	})
+}
+
+//WithStaleWhileRevalidate 设置软过期时间,缓存超过软过期时间后Get依然返回旧数据,同时在后台由获得锁的调用方重新计算
+//软过期时间会和数据一起写入缓存,设置了MaxTTL时softTTL需要小于MaxTTL
+func WithStaleWhileRevalidate(softTTL time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.SoftTTL = softTTL
+	})
+}
+
+//WithXFetch 开启XFetch提前过期,根据缓存函数的耗时在过期前以一定概率提前在后台重新计算
+//未设置软过期时间时以MaxTTL作为过期时间,beta通常设置为1
+func WithXFetch(beta float64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.XFetchBeta = beta
+	})
+}
+
+//WithNear 设置放在redis之前的近端缓存,读取时先查询近端缓存,写入和删除时通知近端缓存失效
+func WithNear(near *Near) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Near = near
+	})
+}
+
+//WithNegativeCache 开启负缓存,缓存函数返回空结果或加载函数未返回的条目会以空值缓存ttl时长,避免不存在的数据反复穿透到数据源
+//Cache会将EmptyResCacheMode设置为EmptyResCacheMode__NEGATIVE,ttl不大于0时忽略该设置
+func WithNegativeCache(ttl time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if ttl <= 0 {
+			return
+		}
+		o.EmptyResCacheMode = EmptyResCacheMode__NEGATIVE
+		o.NegativeTTL = ttl
+	})
+}
+
+//WithExistenceFilter 设置KeyedCache调用加载函数前使用的过滤器,过滤器判断一定不存在的键不会交给加载函数
+//过滤器实现了SeededFilter时(比如RedisBloomFilter,BitmapBloomFilter),在使用Seed写入所有存在的键之前不会用于过滤
+func WithExistenceFilter(filter ExistenceFilter) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Filter = filter
+	})
+}
+
+//WithTags 设置写入缓存时附加的标签,可以通过InvalidateTag删除带有标签的所有缓存
+func WithTags(tags ...string) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Tags = append(o.Tags, tags...)
+	})
+}
+
+//WithTagPrefix 设置标签对应的集合使用的键前缀,默认为`redishelper::cache::tag`
+func WithTagPrefix(prefix string) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.TagPrefix = prefix
+	})
+}
+
+//WithInvalidateBatchSize 设置批量失效时每批处理的键数,默认100
+func WithInvalidateBatchSize(size int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.InvalidateBatchSize = size
+	})
+}
+
 //m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
 func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
 	return optparams.NewFuncOption(func(o *Options) {
@@ -0,0 +1,251 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/keyspace_notifications"
+	"github.com/Golang-Tools/redishelper/v2/pchelper"
+	"github.com/Golang-Tools/redishelper/v2/streamhelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//RefreshOptions 事件触发刷新的配置
+type RefreshOptions struct {
+	Debounce     time.Duration                            //最后一次事件之后等待的时长,期间没有新的事件才执行刷新
+	MaxWait      time.Duration                            //第一次事件之后最多等待的时长,为0时不限制,避免持续不断的事件使刷新一直被推迟
+	Client       redis.UniversalClient                    //监听事件使用的redis客户端,为nil时使用缓存的客户端
+	KeyspaceDB   int                                      //监听键空间通知的db
+	KeyspaceKeys []string                                 //监听键空间通知的源键
+	StreamTopics []string                                 //监听的流
+	StreamOpts   []optparams.Option[streamhelper.Options] //流消费者的配置
+}
+
+//defaultRefreshOptions 事件触发刷新的默认配置
+var defaultRefreshOptions = RefreshOptions{
+	Debounce: 500 * time.Millisecond,
+}
+
+//RefreshWithDebounce 设置最后一次事件之后等待的时长,默认500ms
+func RefreshWithDebounce(d time.Duration) optparams.Option[RefreshOptions] {
+	return optparams.NewFuncOption(func(o *RefreshOptions) {
+		o.Debounce = d
+	})
+}
+
+//RefreshWithMaxWait 设置第一次事件之后最多等待的时长
+func RefreshWithMaxWait(d time.Duration) optparams.Option[RefreshOptions] {
+	return optparams.NewFuncOption(func(o *RefreshOptions) {
+		o.MaxWait = d
+	})
+}
+
+//RefreshWithClient 设置监听事件使用的redis客户端,源数据与缓存不在同一个redis中时使用
+func RefreshWithClient(cli redis.UniversalClient) optparams.Option[RefreshOptions] {
+	return optparams.NewFuncOption(func(o *RefreshOptions) {
+		o.Client = cli
+	})
+}
+
+//RefreshOnKeyspace 源键发生变化时刷新缓存
+//需要redis开启notify-keyspace-events,可以使用keyspace_notifications.KeyspaceNotification.SetConf设置
+//@params db int 源键所在的db
+//@params keys ...string 源键
+func RefreshOnKeyspace(db int, keys ...string) optparams.Option[RefreshOptions] {
+	return optparams.NewFuncOption(func(o *RefreshOptions) {
+		o.KeyspaceDB = db
+		o.KeyspaceKeys = append(o.KeyspaceKeys, keys...)
+	})
+}
+
+//RefreshOnStream 流中有新消息时刷新缓存
+//@params topic string 监听的流
+//@params opts ...optparams.Option[streamhelper.Options] 流消费者的配置
+func RefreshOnStream(topic string, opts ...optparams.Option[streamhelper.Options]) optparams.Option[RefreshOptions] {
+	return optparams.NewFuncOption(func(o *RefreshOptions) {
+		o.StreamTopics = append(o.StreamTopics, topic)
+		o.StreamOpts = append(o.StreamOpts, opts...)
+	})
+}
+
+//Refresher 由外部事件触发缓存刷新的对象
+//一段时间内的多个事件只会触发一次ActiveUpdate
+type Refresher struct {
+	cache      *Cache
+	opt        RefreshOptions
+	trigger    chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	kn         *keyspace_notifications.KeyspaceNotification
+	stream     *streamhelper.Consumer
+	knDone     chan struct{} //监听键空间通知的goroutine退出时关闭
+	streamDone chan struct{} //监听流的goroutine退出时关闭
+	events     int64
+	refreshs   int64
+}
+
+//RefreshOn 监听键空间通知或流,在源数据变化时调用缓存的ActiveUpdate刷新缓存
+//@params c *Cache 要刷新的缓存,Typed缓存可以传入其中的Cache
+//@params opts ...optparams.Option[RefreshOptions] 需要至少设置RefreshOnKeyspace或RefreshOnStream中的一个
+func RefreshOn(c *Cache, opts ...optparams.Option[RefreshOptions]) (*Refresher, error) {
+	r := new(Refresher)
+	r.cache = c
+	r.opt = defaultRefreshOptions
+	optparams.GetOption(&r.opt, opts...)
+	if len(r.opt.KeyspaceKeys) == 0 && len(r.opt.StreamTopics) == 0 {
+		return nil, ErrRefreshNeedSource
+	}
+	cli := r.opt.Client
+	if cli == nil {
+		cli = c.Client()
+	}
+	r.trigger = make(chan struct{}, 1)
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	handler := func(evt *pchelper.Event) error {
+		r.Trigger()
+		return nil
+	}
+	if len(r.opt.KeyspaceKeys) > 0 {
+		knopts := []optparams.Option[keyspace_notifications.Options]{}
+		for _, key := range r.opt.KeyspaceKeys {
+			knopts = append(knopts, keyspace_notifications.WithKeySpaceNotificationHandler(r.opt.KeyspaceDB, key, handler))
+		}
+		kn, err := keyspace_notifications.New(cli, knopts...)
+		if err != nil {
+			return nil, err
+		}
+		r.kn = kn
+		r.knDone = make(chan struct{})
+		go func() {
+			defer close(r.knDone)
+			err := kn.StartWithContext(r.ctx)
+			if err != nil {
+				c.Logger().Error("refresh listen keyspace notification get error", map[string]any{"err": err.Error()})
+			}
+		}()
+	}
+	if len(r.opt.StreamTopics) > 0 {
+		stream, err := streamhelper.NewConsumer(cli, r.opt.StreamOpts...)
+		if err != nil {
+			r.Stop()
+			return nil, err
+		}
+		err = stream.RegistHandler("*", handler)
+		if err != nil {
+			r.Stop()
+			return nil, err
+		}
+		starts, err := streamStarts(r.ctx, cli, r.opt.StreamTopics, r.opt.StreamOpts...)
+		if err != nil {
+			r.Stop()
+			return nil, err
+		}
+		r.stream = stream
+		r.streamDone = make(chan struct{})
+		go func() {
+			defer close(r.streamDone)
+			err := stream.ListenWithContext(r.ctx, strings.Join(r.opt.StreamTopics, ","), pchelper.WithTopicsStartPositionMap(starts))
+			if err != nil {
+				c.Logger().Error("refresh listen stream get error", map[string]any{"err": err.Error()})
+			}
+		}()
+	}
+	go r.loop()
+	return r, nil
+}
+
+//streamStarts 为从最新位置开始监听的流确定具体的起始id
+//不使用消费者组时从`$`开始读取,两次阻塞读取之间写入的消息会被跳过,因此在订阅时取流中最新的消息id作为起始位置,流为空时使用0-0
+//使用消费者组或设置了其他起始位置时不做处理
+func streamStarts(ctx context.Context, cli redis.UniversalClient, topics []string, opts ...optparams.Option[streamhelper.Options]) (map[string]string, error) {
+	sopt := streamhelper.Options{}
+	optparams.GetOption(&sopt, opts...)
+	starts := map[string]string{}
+	if sopt.Group != "" || (sopt.DefaultStart != "" && sopt.DefaultStart != "$") {
+		return starts, nil
+	}
+	for _, topic := range topics {
+		msgs, err := cli.XRevRangeN(ctx, topic, "+", "-", 1).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			starts[topic] = "0-0"
+		} else {
+			starts[topic] = msgs[0].ID
+		}
+	}
+	return starts, nil
+}
+
+//Trigger 手动触发一次事件,与监听到的事件一样受防抖控制
+func (r *Refresher) Trigger() {
+	atomic.AddInt64(&r.events, 1)
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+//Events 收到的事件数
+func (r *Refresher) Events() int64 {
+	return atomic.LoadInt64(&r.events)
+}
+
+//Refreshs 执行刷新的次数
+func (r *Refresher) Refreshs() int64 {
+	return atomic.LoadInt64(&r.refreshs)
+}
+
+//loop 防抖,最后一次事件之后Debounce时长内没有新的事件,或者距第一次事件超过MaxWait时执行刷新
+func (r *Refresher) loop() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+	var timerC <-chan time.Time
+	var first time.Time
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.trigger:
+			now := time.Now()
+			if timerC == nil {
+				first = now
+			}
+			wait := r.opt.Debounce
+			if r.opt.MaxWait > 0 {
+				if remain := first.Add(r.opt.MaxWait).Sub(now); remain < wait {
+					wait = remain
+				}
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			timerC = timer.C
+		case <-timerC:
+			timerC = nil
+			atomic.AddInt64(&r.refreshs, 1)
+			r.cache.ActiveUpdate()
+		}
+	}
+}
+
+//Stop 停止监听并停止刷新,会等待监听的goroutine退出,最多等待流消费者一次阻塞读取的时长
+func (r *Refresher) Stop() {
+	//监听使用r.ctx,无论监听是否已经开始,取消r.ctx后都会退出
+	r.cancel()
+	if r.kn != nil {
+		<-r.knDone
+	}
+	if r.stream != nil {
+		<-r.streamDone
+	}
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/streamhelper"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cache_refresh_on_stream(t *testing.T) {
+	lock, cli1 := NewBackgroundLock(t)
+	defer cli1.Close()
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	_, err := RefreshOn(nil)
+	assert.Equal(t, ErrRefreshNeedSource, err)
+
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithLock(lock), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int64
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", atomic.AddInt64(&count, 1))), nil
+	})
+	refresher, err := RefreshOn(cache,
+		RefreshOnStream("test_cache_source", streamhelper.WithConsumerDefaultStartLatest(), streamhelper.WithBlockTime(100*time.Millisecond)),
+		RefreshWithDebounce(200*time.Millisecond),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "refresh on error")
+	}
+	defer refresher.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	//短时间内的多个事件只触发一次刷新
+	for i := 0; i < 5; i++ {
+		err = cli.XAdd(ctx, &redis.XAddArgs{Stream: "test_cache_source", Values: map[string]any{"i": i}}).Err()
+		if err != nil {
+			assert.FailNow(t, err.Error(), "xadd error")
+		}
+	}
+	time.Sleep(600 * time.Millisecond)
+	assert.Equal(t, int64(5), refresher.Events())
+	assert.Equal(t, int64(1), refresher.Refreshs())
+	res, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+	cli.Del(ctx, "test_cache_source")
+}
+
+func Test_cache_refresh_stop_before_listen(t *testing.T) {
+	cli, _ := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	//监听流的goroutine可能还没有开始监听就被停止
+	for i := 0; i < 5; i++ {
+		refresher, err := RefreshOn(cache, RefreshOnStream("test_cache_source", streamhelper.WithBlockTime(100*time.Millisecond)))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "refresh on error")
+		}
+		start := time.Now()
+		refresher.Stop()
+		assert.Less(t, time.Since(start), time.Second)
+		select {
+		case <-refresher.streamDone:
+		default:
+			assert.FailNow(t, "stream listener still running after stop")
+		}
+	}
+}
+
+func Test_cache_refresh_max_wait(t *testing.T) {
+	cli, _ := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int64
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", atomic.AddInt64(&count, 1))), nil
+	})
+	refresher, err := RefreshOn(cache,
+		RefreshOnKeyspace(0, "test_cache_source"),
+		RefreshWithDebounce(200*time.Millisecond),
+		RefreshWithMaxWait(300*time.Millisecond),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "refresh on error")
+	}
+	defer refresher.Stop()
+	//持续不断的事件在MaxWait后也会触发刷新
+	for i := 0; i < 10; i++ {
+		refresher.Trigger()
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, int64(1), refresher.Refreshs())
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int64(2), refresher.Refreshs())
+	assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+}
+
+func Test_cache_refresh_on_keyspace(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	conf, err := cli.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "config get error")
+	}
+	err = cli.ConfigSet(ctx, "notify-keyspace-events", "KA").Err()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "config set error")
+	}
+	if len(conf) == 2 {
+		defer cli.ConfigSet(ctx, "notify-keyspace-events", conf[1].(string))
+	}
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int64
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", atomic.AddInt64(&count, 1))), nil
+	})
+	refresher, err := RefreshOn(cache,
+		RefreshOnKeyspace(0, "test_cache_source"),
+		RefreshWithDebounce(200*time.Millisecond),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "refresh on error")
+	}
+	defer refresher.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	//写入源键触发键空间通知
+	err = cli.Set(ctx, "test_cache_source", "a", 0).Err()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "set error")
+	}
+	time.Sleep(600 * time.Millisecond)
+	assert.Equal(t, int64(1), refresher.Events())
+	assert.Equal(t, int64(1), refresher.Refreshs())
+	res, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	cli.Del(ctx, "test_cache_source")
+}
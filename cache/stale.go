@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+//staleHeaderSize 开启软过期时写入数据头部的长度,前8位为软过期时间的毫秒时间戳,后8位为缓存函数的耗时(ns)
+const staleHeaderSize = 16
+
+//staleEnabled 是否开启了软过期或提前过期
+func (c *Cache) staleEnabled() bool {
+	return c.opt.SoftTTL > 0 || c.opt.XFetchBeta > 0
+}
+
+//wrapStale 在数据头部写入软过期时间和缓存函数的耗时
+//未设置SoftTTL时以MaxTTL作为软过期时间,两者都未设置时软过期时间为0表示不会过期
+func (c *Cache) wrapStale(res []byte) []byte {
+	ttl := c.opt.SoftTTL
+	if ttl <= 0 {
+		ttl = c.MaxTTL()
+	}
+	var softExpireAt int64
+	if ttl > 0 {
+		softExpireAt = time.Now().Add(ttl).UnixMilli()
+	}
+	buf := make([]byte, staleHeaderSize, staleHeaderSize+len(res))
+	binary.BigEndian.PutUint64(buf[:8], uint64(softExpireAt))
+	binary.BigEndian.PutUint64(buf[8:], uint64(atomic.LoadInt64(&c.lastDelta)))
+	return append(buf, res...)
+}
+
+//unwrapStale 解析数据头部的软过期时间,同时用其中记录的耗时更新本地的耗时
+//@returns []byte 去掉头部后的数据
+//@returns time.Time 软过期时间,为零值表示不会过期
+//@returns bool 数据是否带有合法的头部
+func (c *Cache) unwrapStale(data []byte) ([]byte, time.Time, bool) {
+	if len(data) < staleHeaderSize {
+		return nil, time.Time{}, false
+	}
+	var softExpireAt time.Time
+	if ms := int64(binary.BigEndian.Uint64(data[:8])); ms > 0 {
+		softExpireAt = time.UnixMilli(ms)
+	}
+	if delta := int64(binary.BigEndian.Uint64(data[8:staleHeaderSize])); delta > 0 {
+		atomic.CompareAndSwapInt64(&c.lastDelta, 0, delta)
+	}
+	return data[staleHeaderSize:], softExpireAt, true
+}
+
+//shouldRevalidate 判断是否需要在后台重新计算缓存
+//超过软过期时间一定需要重新计算;设置了XFetchBeta时使用XFetch算法,按缓存函数的耗时以一定概率提前重新计算
+//即当 now - delta * beta * ln(rand()) >= softExpireAt 时提前重新计算
+func (c *Cache) shouldRevalidate(softExpireAt time.Time) bool {
+	if softExpireAt.IsZero() {
+		return false
+	}
+	now := time.Now()
+	if !now.Before(softExpireAt) {
+		return true
+	}
+	if c.opt.XFetchBeta <= 0 {
+		return false
+	}
+	delta := float64(atomic.LoadInt64(&c.lastDelta))
+	if delta <= 0 {
+		return false
+	}
+	gap := time.Duration(-delta * c.opt.XFetchBeta * math.Log(rand.Float64()))
+	return !now.Add(gap).Before(softExpireAt)
+}
+
+//revalidate 在后台重新计算缓存,同一进程同时只会有一个重新计算的任务
+//设置了锁时只有获得锁的调用方会执行重新计算,未获得锁时不等待直接放弃,其他调用方继续使用过期数据
+func (c *Cache) revalidate() {
+	if !atomic.CompareAndSwapInt32(&c.revalidating, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&c.revalidating, 0)
+	var token int64
+	if c.opt.Lock != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.opt.QueryLockTimeout)
+		err := c.opt.Lock.Lock(ctx)
+		cancel()
+		if err != nil {
+			c.Logger().Debug("revalidate not get lock", map[string]any{"err": err.Error()})
+			return
+		}
+		token = c.lockToken()
+		defer c.unlock()
+	}
+	c.refresh(token)
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cache_stale_while_revalidate(t *testing.T) {
+	lock, cli1 := NewBackgroundLock(t)
+	defer cli1.Close()
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	_, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(time.Second), WithStaleWhileRevalidate(time.Second))
+	assert.Equal(t, ErrSoftTTLMustLessThanMaxTTL, err)
+
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithLock(lock), WithMaxTTL(10*time.Second), WithStaleWhileRevalidate(200*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	var count int64
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte(fmt.Sprintf("v%d", atomic.AddInt64(&count, 1))), nil
+	})
+	res, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	time.Sleep(100 * time.Millisecond)
+	res, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+
+	time.Sleep(300 * time.Millisecond)
+	//过期数据依然返回,同时在后台刷新
+	res, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v1", string(res))
+	time.Sleep(100 * time.Millisecond)
+	res, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "v2", string(res))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&count))
+}
+
+func Test_cache_xfetch(t *testing.T) {
+	cli, _ := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := New(cli, WithSpecifiedKey("test_cache"), WithMaxTTL(10*time.Second), WithXFetch(1))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistUpdateFunc(func() ([]byte, error) {
+		return []byte("ok"), nil
+	})
+	data, softExpireAt, ok := cache.unwrapStale(cache.wrapStale([]byte("ok")))
+	assert.True(t, ok)
+	assert.Equal(t, "ok", string(data))
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), softExpireAt, time.Second)
+
+	//耗时远小于剩余时间时几乎不会提前过期
+	atomic.StoreInt64(&cache.lastDelta, int64(time.Millisecond))
+	assert.False(t, cache.shouldRevalidate(time.Now().Add(time.Hour)))
+	//耗时远大于剩余时间时几乎一定提前过期
+	atomic.StoreInt64(&cache.lastDelta, int64(time.Hour))
+	assert.True(t, cache.shouldRevalidate(time.Now().Add(time.Millisecond)))
+	assert.True(t, cache.shouldRevalidate(time.Now().Add(-time.Millisecond)))
+	assert.False(t, cache.shouldRevalidate(time.Time{}))
+}
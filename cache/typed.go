@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/proto"
+)
+
+//TypedCachefunc 返回指定类型结果的缓存函数
+type TypedCachefunc[T any] func() (T, error)
+
+//Typed 指定值类型的缓存
+//值使用设置的编解码器序列化,可以设置压缩器在数据较大时压缩,其他行为与Cache一致
+type Typed[T any] struct {
+	*Cache
+}
+
+//NewTyped 创建一个指定值类型的缓存实例
+func NewTyped[T any](cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Typed[T], error) {
+	c, err := New(cli, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Typed[T]{Cache: c}, nil
+}
+
+//RegistUpdateFunc 注册缓存函数到对象
+func (t *Typed[T]) RegistUpdateFunc(fn TypedCachefunc[T]) error {
+	return t.Cache.RegistUpdateFunc(func() ([]byte, error) {
+		v, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		return t.opt.encode(v)
+	})
+}
+
+//Get 获取数据,行为与Cache.Get一致,结果会被反序列化为T类型
+//@param opt ...optparams.Option[GetOptions] 获取模式设置
+func (t *Typed[T]) Get(ctx context.Context, opt ...optparams.Option[GetOptions]) (T, error) {
+	data, err := t.Cache.Get(ctx, opt...)
+	if err != nil {
+		var v T
+		return v, err
+	}
+	return decodeTo[T](&t.opt, data)
+}
+
+//encode 使用设置的编解码器序列化值
+//设置了压缩器时数据头部会写入1字节的压缩标识,序列化后超过CompressThreshold才会压缩
+func (o *Options) encode(v any) ([]byte, error) {
+	data, err := o.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if o.Compressor == nil {
+		return data, nil
+	}
+	if len(data) <= o.CompressThreshold {
+		return append([]byte{compressFlag__NONE}, data...), nil
+	}
+	compressed, err := o.Compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{o.Compressor.Flag()}, compressed...), nil
+}
+
+//decode 使用设置的编解码器反序列化数据到v
+func (o *Options) decode(data []byte, v any) error {
+	if o.Compressor != nil {
+		if len(data) == 0 {
+			return ErrUnknownCompressFlag
+		}
+		flag := data[0]
+		data = data[1:]
+		if flag != compressFlag__NONE {
+			compressor := o.Compressor
+			if flag != compressor.Flag() {
+				builtin, ok := compressors[flag]
+				if !ok {
+					return ErrUnknownCompressFlag
+				}
+				compressor = builtin
+			}
+			decompressed, err := compressor.Decompress(data)
+			if err != nil {
+				return err
+			}
+			data = decompressed
+		}
+	}
+	return o.Codec.Unmarshal(data, v)
+}
+
+//decodeTo 将数据反序列化为T类型的值,数据为空时返回零值
+//T为proto.Message的指针类型时会先创建对象再反序列化
+func decodeTo[T any](o *Options, data []byte) (T, error) {
+	var v T
+	if len(data) == 0 {
+		return v, nil
+	}
+	if _, ok := any(v).(proto.Message); ok {
+		v = reflect.New(reflect.TypeOf(v).Elem()).Interface().(T)
+		err := o.decode(data, v)
+		return v, err
+	}
+	err := o.decode(data, &v)
+	return v, err
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/pchelper"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testUser struct {
+	ID   int64
+	Name string
+	Tags []string
+}
+
+func Test_typed_cache_codecs(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	for name, codec := range map[string]Codec{"json": JSONCodec{}, "msgpack": MsgpackCodec{}, "gob": GobCodec{}} {
+		cli.FlushDB(ctx)
+		cache, err := NewTyped[testUser](cli, WithSpecifiedKey("test_typed_cache_"+name), WithCodec(codec))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "new cache error")
+		}
+		want := testUser{ID: 42, Name: "hsz", Tags: []string{"a", "b"}}
+		err = cache.RegistUpdateFunc(func() (testUser, error) {
+			return want, nil
+		})
+		if err != nil {
+			assert.FailNow(t, err.Error(), "RegistUpdateFunc error")
+		}
+		got, err := cache.Get(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "first get error")
+		}
+		assert.Equal(t, want, got, name)
+		time.Sleep(100 * time.Millisecond)
+		got, err = cache.Get(ctx, ConstraintMode())
+		if err != nil {
+			assert.FailNow(t, err.Error(), "second get error")
+		}
+		assert.Equal(t, want, got, name)
+	}
+}
+
+func Test_typed_cache_serialize_options(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := NewTyped[testUser](cli, WithSpecifiedKey("test_typed_cache"), WithSerialize(pchelper.SerializeWithMsgpack()))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	want := testUser{ID: 42, Name: "hsz", Tags: []string{"a", "b"}}
+	err = cache.RegistUpdateFunc(func() (testUser, error) {
+		return want, nil
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "RegistUpdateFunc error")
+	}
+	_, err = cache.Get(ctx, StrictMode())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	time.Sleep(100 * time.Millisecond)
+	raw, err := cli.Get(ctx, "test_typed_cache").Bytes()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get raw error")
+	}
+	//与pchelper使用相同的序列化协议
+	var got testUser
+	err = pchelper.Unmarshal(pchelper.SerializeProtocol_MSGPACK, raw, &got)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "unmarshal error")
+	}
+	assert.Equal(t, want, got)
+}
+
+func Test_typed_cache_protobuf(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	cache, err := NewTyped[*wrapperspb.StringValue](cli, WithSpecifiedKey("test_typed_cache"), WithCodec(ProtobufCodec{}))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new cache error")
+	}
+	cache.RegistUpdateFunc(func() (*wrapperspb.StringValue, error) {
+		return wrapperspb.String("hello"), nil
+	})
+	got, err := cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "hello", got.GetValue())
+	time.Sleep(100 * time.Millisecond)
+	got, err = cache.Get(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "hello", got.GetValue())
+}
+
+func Test_typed_cache_compression(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	for _, compressor := range []Compressor{ZstdCompressor{}, SnappyCompressor{}} {
+		cli.FlushDB(ctx)
+		cache, err := NewTyped[string](cli, WithSpecifiedKey("test_typed_cache"), WithCompression(compressor, 64))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "new cache error")
+		}
+		want := strings.Repeat("redishelper", 100)
+		cache.RegistUpdateFunc(func() (string, error) {
+			return want, nil
+		})
+		got, err := cache.Get(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "get error")
+		}
+		assert.Equal(t, want, got)
+		time.Sleep(100 * time.Millisecond)
+		raw, err := cli.Get(ctx, cache.Key()).Bytes()
+		if err != nil {
+			assert.FailNow(t, err.Error(), "get raw error")
+		}
+		assert.Equal(t, compressor.Flag(), raw[0])
+		assert.Less(t, len(raw), len(want))
+		got, err = cache.Get(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "get error")
+		}
+		assert.Equal(t, want, got)
+
+		small, err := cache.opt.encode("small")
+		if err != nil {
+			assert.FailNow(t, err.Error(), "encode error")
+		}
+		assert.Equal(t, byte(compressFlag__NONE), small[0])
+	}
+}
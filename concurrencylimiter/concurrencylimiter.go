@@ -0,0 +1,215 @@
+//Package concurrencylimiter 并发限制器
+//限制同时进行中的请求数,比如整个集群对同一个第三方服务最多同时发起50个调用
+//每个进行中的请求在有序集合中持有一个租约,成员为租约id,分数为租约的过期时间,持有租约的进程崩溃后租约会在过期后被回收
+//租约的过期时间使用redis服务端的时间计算,多个客户端之间不需要保持时钟同步
+package concurrencylimiter
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//acquireScript 清理过期的租约并尝试获取租约
+//KEYS[1]为有序集合,成员为租约id,分数为租约的过期时间(ms)
+//ARGV[1]为租约的过期时间(ms),ARGV[2]为最大并发数,ARGV[3]为租约id,为空时只观测不获取
+//返回{是否获取成功,当前进行中的请求数}
+var acquireScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[1])
+local max = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local count = redis.call("ZCARD", KEYS[1])
+if ARGV[3] == "" then
+	return {1, count}
+end
+if count >= max then
+	return {0, count}
+end
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ttl)
+return {1, count + 1}`)
+
+//renewScript 为租约续期,租约已经过期或被释放时不会重新占用
+//KEYS[1]为有序集合,ARGV[1]为租约的过期时间(ms),ARGV[2]为租约id
+//返回1表示续期成功,0表示租约已经不存在
+var renewScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[1])
+local score = redis.call("ZSCORE", KEYS[1], ARGV[2])
+if not score or tonumber(score) <= now then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ttl)
+return 1`)
+
+//Limiter 并发限制器
+type Limiter struct {
+	opt Options
+	*limiterhelper.LimiterABC
+	*middlewarehelper.MiddleWareAbc
+	prefix string
+	seq    int64
+}
+
+//New 创建一个并发限制器
+//@params client redis.UniversalClient 客户端对象
+//@params opts ...optparams.Option[Options] limiter的可设置项
+func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k := new(Limiter)
+	k.opt = defaultOptions
+	optparams.GetOption(&k.opt, opts...)
+	if k.opt.LeaseTTL <= 0 {
+		return nil, ErrLeaseTTLMustLargerThanZero
+	}
+	if k.opt.RenewInterval >= k.opt.LeaseTTL {
+		return nil, ErrRenewIntervalMustLessThanLeaseTTL
+	}
+	l, err := limiterhelper.New(k.opt.LimiterOpts...)
+	if err != nil {
+		return nil, err
+	}
+	m, err := middlewarehelper.New(cli, "limiter", k.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.MiddleWareAbc = m
+	k.LimiterABC = l
+	k.prefix = hex.EncodeToString(uuid.NewV4().Bytes())
+	return k, nil
+}
+
+//LeaseTTL 租约的过期时间
+func (c *Limiter) LeaseTTL() time.Duration {
+	return c.opt.LeaseTTL
+}
+
+//run 执行获取租约的脚本
+//@params lease string 租约id,为空时只观测
+//@returns bool 是否获取成功
+//@returns int64 当前进行中的请求数
+func (c *Limiter) run(ctx context.Context, lease string) (bool, int64, error) {
+	res, err := acquireScript.Run(ctx, c.Client(), []string{c.Key()}, c.opt.LeaseTTL.Milliseconds(), c.Capacity(), lease).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, ErrScriptResultNotMatch
+	}
+	return res[0] == 1, res[1], nil
+}
+
+//Acquire 获取一个租约,获取成功后必须在请求结束时调用返回的release释放租约
+//进行中的请求数已达上限时返回ErrConcurrencyLimitExceeded,不会阻塞等待
+//获取租约时会按当前进行中的请求数触发OnWarning和OnFull注册的钩子
+//@returns context.Context 由ctx派生的租约上下文,租约丢失(过期被回收或续期失败到租约可能已过期)或被释放时会被取消,持有租约执行的请求应使用它
+//@returns func() 释放租约的函数,可以多次调用,只有第一次生效
+func (c *Limiter) Acquire(ctx context.Context) (context.Context, func(), error) {
+	lease := c.prefix + ":" + strconv.FormatInt(atomic.AddInt64(&c.seq, 1), 10)
+	start := time.Now()
+	ok, level, err := c.run(ctx, lease)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.LimiterABC.CheckWaterline(level, !ok)
+	if !ok {
+		return nil, nil, ErrConcurrencyLimitExceeded
+	}
+	var leaseCtx context.Context
+	var cancel context.CancelFunc
+	stop := make(chan struct{})
+	if c.opt.RenewInterval > 0 {
+		leaseCtx, cancel = context.WithCancel(ctx)
+		go c.renew(lease, start, stop, cancel)
+	} else {
+		//不续期时租约最晚在获取后LeaseTTL过期
+		leaseCtx, cancel = context.WithDeadline(ctx, start.Add(c.opt.LeaseTTL))
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			cancel()
+			_, err := c.Client().ZRem(context.Background(), c.Key(), lease).Result()
+			if err != nil {
+				c.Logger().Warn("concurrency limiter release lease get error", map[string]any{"lease": lease, "err": err.Error()})
+			}
+		})
+	}
+	return leaseCtx, release, nil
+}
+
+//renew 定时为租约续期直到租约被释放
+//租约已经不存在,或者续期持续失败到距离发起最近一次成功续期超过LeaseTTL-RenewInterval时视为租约丢失,调用lost通知持有者
+//@params lastRenew time.Time 发起获取租约请求的时间
+func (c *Limiter) renew(lease string, lastRenew time.Time, stop chan struct{}, lost context.CancelFunc) {
+	ticker := time.NewTicker(c.opt.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), c.opt.RenewInterval)
+			ok, err := renewScript.Run(ctx, c.Client(), []string{c.Key()}, c.opt.LeaseTTL.Milliseconds(), lease).Bool()
+			cancel()
+			if err != nil {
+				c.Logger().Warn("concurrency limiter renew lease get error", map[string]any{"lease": lease, "err": err.Error()})
+				if time.Since(lastRenew) >= c.opt.LeaseTTL-c.opt.RenewInterval {
+					c.Logger().Warn("concurrency limiter lease may be expired", map[string]any{"lease": lease})
+					lost()
+					return
+				}
+				continue
+			}
+			if !ok {
+				c.Logger().Warn("concurrency limiter lease already expired", map[string]any{"lease": lease})
+				lost()
+				return
+			}
+			lastRenew = start
+		}
+	}
+}
+
+//WaterLevel 当前水位,即进行中的请求数,过期的租约不计入
+func (c *Limiter) WaterLevel(ctx context.Context) (int64, error) {
+	_, level, err := c.run(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	return level, nil
+}
+
+//IsFull 观测进行中的请求数是否已达上限
+func (c *Limiter) IsFull(ctx context.Context) (bool, error) {
+	level, err := c.WaterLevel(ctx)
+	if err != nil {
+		return false, err
+	}
+	return level >= c.Capacity(), nil
+}
+
+//Reset 重置限制器,所有租约都会被清除
+func (c *Limiter) Reset(ctx context.Context) error {
+	_, err := c.Client().Del(ctx, c.Key()).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package concurrencylimiter
+
+import (
+	"errors"
+)
+
+//ErrLeaseTTLMustLargerThanZero 租约的过期时间必须大于0
+var ErrLeaseTTLMustLargerThanZero = errors.New("lease ttl must larger than 0")
+
+//ErrRenewIntervalMustLessThanLeaseTTL 租约的续期间隔必须小于租约的过期时间
+var ErrRenewIntervalMustLessThanLeaseTTL = errors.New("renew interval must less than lease ttl")
+
+//ErrConcurrencyLimitExceeded 同时进行中的请求数已达上限
+var ErrConcurrencyLimitExceeded = errors.New("concurrency limit exceeded")
+
+//ErrScriptResultNotMatch 脚本的返回值不符合预期
+var ErrScriptResultNotMatch = errors.New("script result not match")
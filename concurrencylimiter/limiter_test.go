@@ -0,0 +1,191 @@
+package concurrencylimiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+func Test_limiter_options(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	_, err := New(ck, WithWarningSize(120))
+	assert.Equal(t, limiterhelper.ErrLimiterMaxSizeMustLargerThanWaringSize, err)
+	_, err = New(ck, WithLeaseTTL(0))
+	assert.Equal(t, ErrLeaseTTLMustLargerThanZero, err)
+	_, err = New(ck, WithLeaseTTL(time.Second), WithRenewInterval(time.Second))
+	assert.Equal(t, ErrRenewIntervalMustLessThanLeaseTTL, err)
+}
+
+func Test_limiter_acquire(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(3), WithWarningSize(2), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	releases := []func(){}
+	for i := 0; i < 3; i++ {
+		_, release, err := limiter.Acquire(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Acquire get error")
+		}
+		releases = append(releases, release)
+	}
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(3), wl)
+	full, err := limiter.IsFull(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter IsFull get error")
+	}
+	assert.Equal(t, true, full)
+	_, _, err = limiter.Acquire(ctx)
+	assert.Equal(t, ErrConcurrencyLimitExceeded, err)
+	//重复释放只生效一次
+	releases[0]()
+	releases[0]()
+	wl, err = limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(2), wl)
+	_, release, err := limiter.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Acquire get error")
+	}
+	release()
+	for _, release := range releases[1:] {
+		release()
+	}
+	wl, err = limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(0), wl)
+}
+
+func Test_limiter_lease_expire(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(2), WithWarningSize(1), WithLeaseTTL(300*time.Millisecond), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	renewed, err := New(ck, WithMaxSize(2), WithWarningSize(1), WithLeaseTTL(300*time.Millisecond), WithRenewInterval(100*time.Millisecond), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	//模拟崩溃的进程,获取后不释放
+	_, _, err = limiter.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Acquire get error")
+	}
+	_, release, err := renewed.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Acquire get error")
+	}
+	defer release()
+	time.Sleep(500 * time.Millisecond)
+	//未续期的租约过期被回收,续期的租约依然有效
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(1), wl)
+}
+
+func Test_limiter_lease_lost(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(2), WithWarningSize(1), WithLeaseTTL(300*time.Millisecond), WithRenewInterval(100*time.Millisecond), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	leaseCtx, release, err := limiter.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Acquire get error")
+	}
+	defer release()
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, leaseCtx.Err())
+	//租约被回收后续期失败,租约上下文被取消
+	err = limiter.Reset(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Reset get error")
+	}
+	select {
+	case <-leaseCtx.Done():
+	case <-time.After(time.Second):
+		assert.FailNow(t, "lease ctx not canceled after lease lost")
+	}
+	//不续期的租约上下文在租约过期时结束
+	noRenew, err := New(ck, WithMaxSize(2), WithWarningSize(1), WithLeaseTTL(300*time.Millisecond), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	leaseCtx, release2, err := noRenew.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Acquire get error")
+	}
+	defer release2()
+	select {
+	case <-leaseCtx.Done():
+		assert.Equal(t, context.DeadlineExceeded, leaseCtx.Err())
+	case <-time.After(time.Second):
+		assert.FailNow(t, "lease ctx not canceled after lease expired")
+	}
+}
+
+func Test_limiter_hooks(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(3), WithWarningSize(2), WithSpecifiedKey("test_concurrencylimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	var lock sync.Mutex
+	warnings := []int64{}
+	fulls := []int64{}
+	limiter.OnWarning(func(res, maxsize int64) error {
+		lock.Lock()
+		defer lock.Unlock()
+		warnings = append(warnings, res)
+		return nil
+	})
+	limiter.OnFull(func(res, maxsize int64) error {
+		lock.Lock()
+		defer lock.Unlock()
+		fulls = append(fulls, res)
+		return nil
+	})
+	for i := 0; i < 4; i++ {
+		limiter.Acquire(ctx)
+	}
+	assert.Equal(t, []int64{2}, warnings)
+	assert.Equal(t, []int64{3, 3}, fulls)
+}
@@ -4,6 +4,7 @@ package celllimiter
 
 import (
 	"context"
+	"time"
 
 	"github.com/Golang-Tools/optparams"
 	"github.com/Golang-Tools/redishelper/v2/exthelper/cellhelper"
@@ -37,17 +38,64 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter
 	return k, nil
 }
 
-func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
+//reservation 将令牌桶状态转换为注水结果
+func reservation(res *cellhelper.RedisCellStatus) *limiterhelper.Reservation {
+	retryAfter := time.Duration(res.WaitForRetryTime) * time.Second
+	if res.WaitForRetryTime < 0 {
+		retryAfter = -1
+	}
+	return limiterhelper.NewReservation(!res.Blocked, res.Max, res.Max-res.Remaining, retryAfter, time.Duration(res.ResetToMaxTime)*time.Second)
+}
+
+//Take 注水并返回详细结果,redis-cell返回的时间精度为秒
+func (c *Limiter) Take(ctx context.Context, value int64) (*limiterhelper.Reservation, error) {
 	res, err := c.ClThrottle(ctx, value, cellhelper.RefreshTTL())
 	if err != nil {
-		return true, err
+		return nil, err
 	}
-	size := (res.Max - res.Remaining)
+	return reservation(res), nil
+}
+
+//Wait 阻塞直到注水成功或ctx结束
+//redis-cell返回的等待时间精度为秒,不足一秒时会按limiterhelper.MinWaitInterval的间隔重试
+func (c *Limiter) Wait(ctx context.Context, value int64) error {
+	return limiterhelper.Wait(ctx, c.Take, value)
+}
 
-	full := c.LimiterABC.CheckWaterline(size, res.Blocked)
+func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
+	res, err := c.Take(ctx, value)
+	if err != nil {
+		return true, err
+	}
+	full := c.LimiterABC.CheckWaterline(res.WaterLevel(), !res.Allowed)
 	return !full, nil
 }
 
+//TakeKey 对指定键对应的令牌桶注水并返回详细结果,令牌桶的容量为maxsize,令牌的补充速率使用设置的CountPerPeriod和Period
+//@params key string 令牌桶使用的键
+//@params maxsize int64 令牌桶的容量
+//@params value int64 注水量
+func (c *Limiter) TakeKey(ctx context.Context, key string, maxsize int64, value int64) (*limiterhelper.Reservation, error) {
+	res, err := c.ClThrottleKey(ctx, key, value, cellhelper.WithMaxBurst(maxsize-1), cellhelper.RefreshTTL())
+	if err != nil {
+		return nil, err
+	}
+	return reservation(res), nil
+}
+
+//FloodKey 对指定键对应的令牌桶注水,令牌桶的容量为maxsize,令牌的补充速率使用设置的CountPerPeriod和Period
+//当返回为true说明注水成功,false表示无法注入(满了)
+//@params key string 令牌桶使用的键
+//@params maxsize int64 令牌桶的容量
+//@params value int64 注水量
+func (c *Limiter) FloodKey(ctx context.Context, key string, maxsize int64, value int64) (bool, error) {
+	res, err := c.TakeKey(ctx, key, maxsize, value)
+	if err != nil {
+		return true, err
+	}
+	return res.Allowed, nil
+}
+
 func (c *Limiter) WaterLevel(ctx context.Context) (int64, error) {
 	res, err := c.ClThrottle(ctx, 0, cellhelper.RefreshTTL())
 	if err != nil {
@@ -55,6 +55,14 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*RedisCe
 //@params count int64 增加的token数量
 //@params  opts ...optparams.Option[ClThrottleOpts] 其他参数
 func (c *RedisCell) ClThrottle(ctx context.Context, count int64, opts ...optparams.Option[ClThrottleOpts]) (*RedisCellStatus, error) {
+	return c.ClThrottleKey(ctx, c.Key(), count, opts...)
+}
+
+//ClThrottleKey 增加指定键对应的桶中token数并返回桶状态,用于使用同一配置管理多个令牌桶
+//@params key string 令牌桶使用的键
+//@params count int64 增加的token数量
+//@params  opts ...optparams.Option[ClThrottleOpts] 其他参数
+func (c *RedisCell) ClThrottleKey(ctx context.Context, key string, count int64, opts ...optparams.Option[ClThrottleOpts]) (*RedisCellStatus, error) {
 	defaultopt := ClThrottleOpts{}
 	optparams.GetOption(&defaultopt, opts...)
 	refreshopt := middlewarehelper.RefreshOpt{}
@@ -85,21 +93,21 @@ func (c *RedisCell) ClThrottle(ctx context.Context, count int64, opts ...optpara
 	var ok bool
 	if ttl > 0 && refreshopt.RefreshTTL != middlewarehelper.RefreshTTLType_NoRefresh {
 		if refreshopt.RefreshTTL == middlewarehelper.RefreshTTLType_Refresh {
-			res, err := c.DoCmdWithTTL(ctx, cmd, c.Key(), ttl, maxBurst, countPerPeriod, period, count)
+			res, err := c.DoCmdWithTTL(ctx, cmd, key, ttl, maxBurst, countPerPeriod, period, count)
 			if err != nil {
 				return nil, err
 			}
 			infos, ok = res.([]interface{})
 		} else {
-			exists, err := c.MiddleWareAbc.Exists(ctx)
+			exists, err := c.Client().Exists(ctx, key).Result()
 			if err != nil {
 				return nil, err
 			}
 			var res interface{}
-			if exists {
-				res, err = c.Client().Do(ctx, cmd, c.Key(), maxBurst, countPerPeriod, period, count).Result()
+			if exists != 0 {
+				res, err = c.Client().Do(ctx, cmd, key, maxBurst, countPerPeriod, period, count).Result()
 			} else {
-				res, err = c.DoCmdWithTTL(ctx, cmd, c.Key(), ttl, maxBurst, countPerPeriod, period, count)
+				res, err = c.DoCmdWithTTL(ctx, cmd, key, ttl, maxBurst, countPerPeriod, period, count)
 			}
 			if err != nil {
 				return nil, err
@@ -107,7 +115,7 @@ func (c *RedisCell) ClThrottle(ctx context.Context, count int64, opts ...optpara
 			infos, ok = res.([]interface{})
 		}
 	} else {
-		res, err := c.Client().Do(ctx, cmd, c.Key(), maxBurst, countPerPeriod, period, count).Result()
+		res, err := c.Client().Do(ctx, cmd, key, maxBurst, countPerPeriod, period, count).Result()
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,8 @@
+package gcralimiter
+
+import (
+	"errors"
+)
+
+//ErrRateMustLargerThanZero 令牌桶的CountPerPeriod和Period必须大于0
+var ErrRateMustLargerThanZero = errors.New("count per period and period must larger than 0")
@@ -0,0 +1,204 @@
+package gcralimiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/exthelper/cellhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//gcraScript 使用GCRA算法实现的令牌桶,与redis-cell的CL.THROTTLE语义一致
+//使用redis服务端的时间,时间单位为us,键中保存理论到达时间(TAT)
+//KEYS[1]为令牌桶的键
+//ARGV[1]为MaxBurst,ARGV[2]为CountPerPeriod,ARGV[3]为Period(s),ARGV[4]为本次消耗的token数
+//ARGV[5]为刷新过期时间的模式(0不刷新,1仅在第一次设置时刷新,2总是刷新),ARGV[6]为刷新使用的过期时间(ms)
+//返回{是否被限制,最大token数,剩余token数,需要等待的时间(ms,不用等为-1),桶回满需要的时间(ms)}
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local burst = tonumber(ARGV[1])
+local count = tonumber(ARGV[2])
+local period = tonumber(ARGV[3]) * 1000000
+local quantity = tonumber(ARGV[4])
+local refresh = tonumber(ARGV[5])
+local ttl = tonumber(ARGV[6])
+local emission = period / count
+local dvt = emission * (burst + 1)
+local limit = burst + 1
+local stored = redis.call("GET", KEYS[1])
+local tat = now
+if stored then
+	tat = math.max(tonumber(stored), now)
+end
+local increment = emission * quantity
+local newTat = tat + increment
+local diff = now - (newTat - dvt)
+local limited = 0
+local retryAfter = -1
+local resetAfter = 0
+if diff < 0 then
+	limited = 1
+	if increment <= dvt then
+		retryAfter = math.ceil(-diff / 1000)
+	end
+	resetAfter = tat - now
+else
+	resetAfter = newTat - now
+	if resetAfter > 0 then
+		redis.call("SET", KEYS[1], string.format("%.0f", newTat), "PX", math.ceil(resetAfter / 1000))
+	end
+end
+if ttl > 0 and (refresh == 2 or (refresh == 1 and not stored)) and redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+local remaining = 0
+local next = dvt - resetAfter
+if next > -emission then
+	remaining = math.floor(next / emission)
+end
+return {limited, limit, remaining, retryAfter, math.ceil(resetAfter / 1000)}`)
+
+//refundScript 归还令牌,将理论到达时间(TAT)提前归还的令牌对应的时长,不会早于当前时间
+//KEYS[1]为令牌桶的键,ARGV[1]为CountPerPeriod,ARGV[2]为Period(s),ARGV[3]为归还的token数
+//返回1表示归还成功,0表示令牌桶已经是满的
+var refundScript = redis.NewScript(`
+redis.replicate_commands()
+local stored = redis.call("GET", KEYS[1])
+if not stored then
+	return 0
+end
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local emission = tonumber(ARGV[2]) * 1000000 / tonumber(ARGV[1])
+local tat = tonumber(stored) - emission * tonumber(ARGV[3])
+if tat <= now then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], string.format("%.0f", tat), "PX", ttl)
+else
+	redis.call("SET", KEYS[1], string.format("%.0f", tat))
+end
+return 1`)
+
+//Bucket 使用lua脚本实现的令牌桶对象
+//与cellhelper.RedisCell的用法一致,但不需要redis加载redis-cell模块,因此可以用于不能加载模块的托管redis
+type Bucket struct {
+	*middlewarehelper.MiddleWareAbc
+	opt cellhelper.Options
+}
+
+//NewBucket 创建一个新的令牌桶对象
+//@params cli redis.UniversalClient
+//@params opts ...optparams.Option[cellhelper.Options] 与cellhelper.New一致的配置
+func NewBucket(cli redis.UniversalClient, opts ...optparams.Option[cellhelper.Options]) (*Bucket, error) {
+	bm := new(Bucket)
+	bm.opt = cellhelper.Defaultopt
+	optparams.GetOption(&bm.opt, opts...)
+	if bm.opt.CountPerPeriod <= 0 || bm.opt.Period <= 0 {
+		return nil, ErrRateMustLargerThanZero
+	}
+	mi, err := middlewarehelper.New(cli, bm.opt.Middlewaretype, bm.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	bm.MiddleWareAbc = mi
+	return bm, nil
+}
+
+//Refund 向令牌桶归还令牌,用于归还预先获取但没有用完的令牌
+//@params count int64 归还的token数
+func (c *Bucket) Refund(ctx context.Context, count int64) error {
+	_, err := refundScript.Run(ctx, c.Client(), []string{c.Key()}, c.opt.CountPerPeriod, c.opt.Period, count).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+//throttle 执行令牌桶脚本
+//@returns int64 需要等待的时间(ms),不用等为-1
+//@returns int64 桶回满需要的时间(ms)
+func (c *Bucket) throttle(ctx context.Context, key string, count int64, opts ...optparams.Option[cellhelper.ClThrottleOpts]) (*cellhelper.RedisCellStatus, int64, int64, error) {
+	defaultopt := cellhelper.ClThrottleOpts{}
+	optparams.GetOption(&defaultopt, opts...)
+	refreshopt := middlewarehelper.RefreshOpt{}
+	optparams.GetOption(&refreshopt, defaultopt.RefreshOpts...)
+	var ttl time.Duration = 0
+	if refreshopt.TTL > 0 {
+		ttl = refreshopt.TTL
+	} else {
+		if c.MaxTTL() > 0 {
+			ttl = c.MaxTTL()
+		}
+	}
+	maxBurst := c.opt.MaxBurst
+	if defaultopt.MaxBurst > 0 {
+		maxBurst = defaultopt.MaxBurst
+	}
+	countPerPeriod := c.opt.CountPerPeriod
+	if defaultopt.CountPerPeriod > 0 {
+		countPerPeriod = defaultopt.CountPerPeriod
+	}
+	period := c.opt.Period
+	if defaultopt.Period > 0 {
+		period = defaultopt.Period
+	}
+	infos, err := gcraScript.Run(ctx, c.Client(), []string{key}, maxBurst, countPerPeriod, period, count, int64(refreshopt.RefreshTTL), ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(infos) != 5 {
+		return nil, 0, 0, fmt.Errorf("gcra script results not ok")
+	}
+	retryAfter := infos[3]
+	resetAfter := infos[4]
+	result := &cellhelper.RedisCellStatus{
+		Blocked:          infos[0] != 0,
+		Max:              infos[1],
+		Remaining:        infos[2],
+		WaitForRetryTime: -1,
+		ResetToMaxTime:   ceilSeconds(resetAfter),
+	}
+	if retryAfter >= 0 {
+		result.WaitForRetryTime = ceilSeconds(retryAfter)
+	}
+	return result, retryAfter, resetAfter, nil
+}
+
+//ClThrottle 增加桶中token数并返回桶状态,参数和返回值与cellhelper.RedisCell.ClThrottle一致
+//@params count int64 增加的token数量
+//@params  opts ...optparams.Option[cellhelper.ClThrottleOpts] 其他参数
+func (c *Bucket) ClThrottle(ctx context.Context, count int64, opts ...optparams.Option[cellhelper.ClThrottleOpts]) (*cellhelper.RedisCellStatus, error) {
+	return c.ClThrottleKey(ctx, c.Key(), count, opts...)
+}
+
+//ClThrottleKey 增加指定键对应的桶中token数并返回桶状态,参数和返回值与cellhelper.RedisCell.ClThrottleKey一致
+//@params key string 令牌桶使用的键
+//@params count int64 增加的token数量
+//@params  opts ...optparams.Option[cellhelper.ClThrottleOpts] 其他参数
+func (c *Bucket) ClThrottleKey(ctx context.Context, key string, count int64, opts ...optparams.Option[cellhelper.ClThrottleOpts]) (*cellhelper.RedisCellStatus, error) {
+	res, _, _, err := c.throttle(ctx, key, count, opts...)
+	return res, err
+}
+
+//Clean 清除令牌桶的key
+func (c *Bucket) Clean(ctx context.Context) error {
+	_, err := c.Client().Del(ctx, c.Key()).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+//ceilSeconds 将毫秒向上取整为秒,避免两次调用之间经过的几毫秒使结果少1秒
+func ceilSeconds(ms int64) int64 {
+	return (ms + 999) / 1000
+}
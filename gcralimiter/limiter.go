@@ -0,0 +1,116 @@
+//Package gcralimiter 使用lua脚本实现GCRA算法的令牌桶限制器
+//与exthelper/cellhelper/celllimiter的配置和行为一致,但不依赖redis-cell模块,将导入的celllimiter替换为gcralimiter即可切换
+//可以用于防止短时间内大量请求同时处理,比如缓存防击穿,防爬虫等
+package gcralimiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/exthelper/cellhelper"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//Limiter 分布式限制器
+type Limiter struct {
+	opt Options
+	*Bucket
+	*limiterhelper.LimiterABC
+}
+
+//New 构造令牌桶限流器
+func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k := new(Limiter)
+	k.opt = defaultOptions
+	optparams.GetOption(&k.opt, opts...)
+	l, err := limiterhelper.New(k.opt.LimiterOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.opt.CellOpts = append(k.opt.CellOpts, cellhelper.WithMiddlewaretype("limiter"))
+	c, err := NewBucket(cli, k.opt.CellOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.LimiterABC = l
+	k.Bucket = c
+	return k, nil
+}
+
+//take 执行令牌桶脚本并构造注水结果,时间精度为毫秒
+func (c *Limiter) take(ctx context.Context, key string, value int64, opts ...optparams.Option[cellhelper.ClThrottleOpts]) (*limiterhelper.Reservation, error) {
+	res, retryAfter, resetAfter, err := c.throttle(ctx, key, value, opts...)
+	if err != nil {
+		return nil, err
+	}
+	retry := time.Duration(retryAfter) * time.Millisecond
+	if retryAfter < 0 {
+		retry = -1
+	}
+	return limiterhelper.NewReservation(!res.Blocked, res.Max, res.Max-res.Remaining, retry, time.Duration(resetAfter)*time.Millisecond), nil
+}
+
+//Take 注水并返回详细结果
+func (c *Limiter) Take(ctx context.Context, value int64) (*limiterhelper.Reservation, error) {
+	return c.take(ctx, c.Key(), value, cellhelper.RefreshTTL())
+}
+
+//Wait 阻塞直到注水成功或ctx结束
+//注水失败时按令牌补充需要的时间等待后重试
+func (c *Limiter) Wait(ctx context.Context, value int64) error {
+	return limiterhelper.Wait(ctx, c.Take, value)
+}
+
+//Flood 灌注
+//当返回为true说明注水成功,false表示无法注入(满了)
+func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
+	res, err := c.Take(ctx, value)
+	if err != nil {
+		return true, err
+	}
+	full := c.LimiterABC.CheckWaterline(res.WaterLevel(), !res.Allowed)
+	return !full, nil
+}
+
+//TakeKey 对指定键对应的令牌桶注水并返回详细结果,令牌桶的容量为maxsize,令牌的补充速率使用设置的CountPerPeriod和Period
+//@params key string 令牌桶使用的键
+//@params maxsize int64 令牌桶的容量
+//@params value int64 注水量
+func (c *Limiter) TakeKey(ctx context.Context, key string, maxsize int64, value int64) (*limiterhelper.Reservation, error) {
+	return c.take(ctx, key, value, cellhelper.WithMaxBurst(maxsize-1), cellhelper.RefreshTTL())
+}
+
+//FloodKey 对指定键对应的令牌桶注水,令牌桶的容量为maxsize,令牌的补充速率使用设置的CountPerPeriod和Period
+//当返回为true说明注水成功,false表示无法注入(满了)
+//@params key string 令牌桶使用的键
+//@params maxsize int64 令牌桶的容量
+//@params value int64 注水量
+func (c *Limiter) FloodKey(ctx context.Context, key string, maxsize int64, value int64) (bool, error) {
+	res, err := c.TakeKey(ctx, key, maxsize, value)
+	if err != nil {
+		return true, err
+	}
+	return res.Allowed, nil
+}
+
+//WaterLevel 当前水位
+func (c *Limiter) WaterLevel(ctx context.Context) (int64, error) {
+	res, err := c.ClThrottle(ctx, 0, cellhelper.RefreshTTL())
+	if err != nil {
+		return 0, err
+	}
+	return (res.Max - res.Remaining), err
+}
+
+//IsFull 观测水位是否已满
+func (c *Limiter) IsFull(ctx context.Context) (bool, error) {
+	notfull, err := c.Flood(ctx, 0)
+	return !notfull, err
+}
+
+//Reset 重置限制器
+func (c *Limiter) Reset(ctx context.Context) error {
+	return c.Clean(ctx)
+}
@@ -0,0 +1,266 @@
+package gcralimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/exthelper/cellhelper"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	cli.FlushDB(ctx).Result()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+func Test_limiter_warningsize_larger_than_max(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	_, err := New(ck, WithMaxTTL(5*time.Second), WithWarningSize(120))
+	assert.Equal(t, limiterhelper.ErrLimiterMaxSizeMustLargerThanWaringSize, err)
+}
+
+func Test_limiter_interface(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(5*time.Second), WithMaxSize(120))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	// 开始测试
+	assert.Equal(t, int64(120), limiter.Capacity())
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(0), wl)
+	res, err := limiter.Flood(ctx, 11)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, res)
+	wl, err = limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(11), wl)
+
+	isfull, err := limiter.IsFull(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter IsFull get error")
+	}
+	assert.Equal(t, false, isfull)
+	res, err = limiter.Flood(ctx, 109)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, false, res)
+	isfull, err = limiter.IsFull(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter IsFull get error")
+	}
+	assert.Equal(t, true, isfull)
+
+	err = limiter.Reset(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Reset get error")
+	}
+	isfull, err = limiter.IsFull(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter IsFull get error")
+	}
+	assert.Equal(t, false, isfull)
+}
+
+func Test_limiter_canExp(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(5*time.Second), WithSpecifiedKey("test_incrlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+
+	for i := 0; i < 9; i++ {
+		res, err := limiter.Flood(ctx, 11)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res)
+	}
+	for i := 0; i < 9; i++ {
+		res, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, false, res)
+	}
+	time.Sleep(10 * time.Second)
+	for i := 0; i < 9; i++ {
+		res, err := limiter.Flood(ctx, 11)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res)
+	}
+	for i := 0; i < 9; i++ {
+		res, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, false, res)
+	}
+}
+
+func Test_limiter_hooks(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(5*time.Second), WithSpecifiedKey("test_incrlimiter"))
+	limiter.OnFull(func(res, maxsize int64) error {
+		assert.GreaterOrEqual(t, res, maxsize)
+		return nil
+	})
+	limiter.OnWarning(func(res, maxsize int64) error {
+		assert.GreaterOrEqual(t, maxsize, res)
+		return nil
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	_, err = limiter.Flood(ctx, 80)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	_, err = limiter.Flood(ctx, 20)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	time.Sleep(time.Second)
+}
+
+func Test_bucket_clthrottle(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	_, err := NewBucket(ck, cellhelper.WithDefaultPeriod(0))
+	assert.Equal(t, ErrRateMustLargerThanZero, err)
+	bucket, err := NewBucket(ck, cellhelper.WithSpecifiedKey("test_gcra"), cellhelper.WithDefaultMaxBurst(4), cellhelper.WithDefaultCountPerPeriod(1), cellhelper.WithDefaultPeriod(1))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket new get error")
+	}
+	res, err := bucket.ClThrottle(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket ClThrottle get error")
+	}
+	assert.Equal(t, false, res.Blocked)
+	assert.Equal(t, int64(5), res.Max)
+	assert.Equal(t, int64(4), res.Remaining)
+	assert.Equal(t, int64(-1), res.WaitForRetryTime)
+	assert.Equal(t, int64(1), res.ResetToMaxTime)
+	res, err = bucket.ClThrottle(ctx, 4)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket ClThrottle get error")
+	}
+	assert.Equal(t, false, res.Blocked)
+	assert.Equal(t, int64(0), res.Remaining)
+	assert.Equal(t, int64(5), res.ResetToMaxTime)
+	res, err = bucket.ClThrottle(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket ClThrottle get error")
+	}
+	assert.Equal(t, true, res.Blocked)
+	assert.Equal(t, int64(0), res.Remaining)
+	assert.GreaterOrEqual(t, res.WaitForRetryTime, int64(0))
+	//超过桶容量的请求永远无法满足
+	res, err = bucket.ClThrottle(ctx, 6)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket ClThrottle get error")
+	}
+	assert.Equal(t, true, res.Blocked)
+	assert.Equal(t, int64(-1), res.WaitForRetryTime)
+	time.Sleep(1100 * time.Millisecond)
+	res, err = bucket.ClThrottle(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket ClThrottle get error")
+	}
+	assert.Equal(t, false, res.Blocked)
+	err = bucket.Clean(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "bucket Clean get error")
+	}
+}
+
+func Test_limiter_take(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(5), WithWarningSize(3), WithDefaultCountPerPeriod(5), WithDefaultPeriod(1), WithSpecifiedKey("test_gcralimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	var _ limiterhelper.TakerInterface = limiter
+	var _ limiterhelper.KeyedFlooderInterface = limiter
+	res, err := limiter.Take(ctx, 5)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, true, res.Allowed)
+	assert.Equal(t, int64(5), res.Limit)
+	assert.Equal(t, int64(0), res.Remaining)
+	res, err = limiter.Take(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, false, res.Allowed)
+	//每200ms补充一个令牌
+	assert.Greater(t, res.RetryAfter, 100*time.Millisecond)
+	assert.LessOrEqual(t, res.RetryAfter, 200*time.Millisecond)
+	assert.WithinDuration(t, time.Now().Add(time.Second), res.ResetAt, 100*time.Millisecond)
+	time.Sleep(res.RetryAfter + 10*time.Millisecond)
+	res, err = limiter.Take(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, true, res.Allowed)
+	res, err = limiter.Take(ctx, 6)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, time.Duration(-1), res.RetryAfter)
+}
+
+func Test_limiter_wait(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxSize(5), WithWarningSize(3), WithDefaultCountPerPeriod(10), WithDefaultPeriod(1), WithSpecifiedKey("test_gcralimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	start := time.Now()
+	//前5个使用桶中的令牌,之后每100ms补充一个
+	for i := 0; i < 10; i++ {
+		err := limiter.Wait(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Wait get error")
+		}
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+	err = limiter.Wait(ctx, 6)
+	assert.Equal(t, limiterhelper.ErrWaitNeverAllowed, err)
+}
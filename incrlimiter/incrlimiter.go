@@ -5,6 +5,7 @@ package incrlimiter
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Golang-Tools/optparams"
 	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
@@ -12,6 +13,72 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+//注水规则:注水后的水位不超过容量即可注水成功,一个窗口内最多可以注入容量大小的水量
+//注水量为0时只观测,水位未到达容量(还能注入)时视为成功
+
+//takeScript 原子的注水
+//注水失败时超出容量的部分会被扣除,水位保持在容量
+//KEYS[1]为计数使用的键,ARGV[1]为最大容量,ARGV[2]为注水量,ARGV[3]为窗口时长(ms)
+//返回{是否注水成功,当前水位,窗口剩余时长(ms)}
+var takeScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local res = 0
+if n <= 0 then
+	res = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if res < max then
+		return {1, res, math.max(redis.call("PTTL", KEYS[1]), 0)}
+	end
+	return {0, res, math.max(redis.call("PTTL", KEYS[1]), 0)}
+end
+res = redis.call("INCRBY", KEYS[1], n)
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+local allowed = 1
+if res > max then
+	allowed = 0
+	redis.call("DECRBY", KEYS[1], res - max)
+	res = max
+end
+return {allowed, res, math.max(redis.call("PTTL", KEYS[1]), 0)}`)
+
+//takeKeyScript 对指定键原子的注水,注水规则与takeScript一致,但无法注水时不会占用容量
+//KEYS[1]为计数使用的键,ARGV[1]为最大容量,ARGV[2]为注水量,ARGV[3]为窗口时长(ms)
+//返回{是否注水成功,当前水位,窗口剩余时长(ms)}
+var takeKeyScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local cur = tonumber(redis.call("GET", KEYS[1]) or "0")
+if n <= 0 then
+	if cur < max then
+		return {1, cur, math.max(redis.call("PTTL", KEYS[1]), 0)}
+	end
+	return {0, cur, math.max(redis.call("PTTL", KEYS[1]), 0)}
+end
+if cur + n > max then
+	return {0, cur, math.max(redis.call("PTTL", KEYS[1]), 0)}
+end
+local res = redis.call("INCRBY", KEYS[1], n)
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return {1, res, redis.call("PTTL", KEYS[1])}`)
+
+//refundScript 归还注水量,键不存在(窗口已经结束)时不归还,水位不会低于0
+//KEYS[1]为计数使用的键,ARGV[1]为归还的量
+//返回实际归还的量
+var refundScript = redis.NewScript(`
+local cur = redis.call("GET", KEYS[1])
+if not cur then
+	return 0
+end
+local n = math.min(tonumber(cur), tonumber(ARGV[1]))
+if n > 0 then
+	redis.call("DECRBY", KEYS[1], n)
+end
+return n`)
+
 //Limiter 分布式限制器
 type Limiter struct {
 	opt Options
@@ -42,38 +109,83 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter
 	return k, nil
 }
 
+//run 执行注水脚本并构造注水结果
+//@params never bool 本次注水是否永远无法成功
+func (c *Limiter) run(ctx context.Context, script *redis.Script, key string, maxsize, value int64, never bool) (*limiterhelper.Reservation, error) {
+	res, err := script.Run(ctx, c.Client(), []string{key}, maxsize, value, c.MaxTTL().Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 3 {
+		return nil, errors.New("take script results not ok")
+	}
+	allowed := res[0] == 1
+	resetAfter := time.Duration(res[2]) * time.Millisecond
+	retryAfter := resetAfter
+	if never {
+		retryAfter = -1
+	}
+	return limiterhelper.NewReservation(allowed, maxsize, res[1], retryAfter, resetAfter), nil
+}
+
+//Take 注水并返回详细结果,整个过程在一个lua脚本中原子执行
+//注水后水位超过容量即视为注水失败,超出容量的部分会被扣除,注水失败时需要等待当前窗口结束
+func (c *Limiter) Take(ctx context.Context, value int64) (*limiterhelper.Reservation, error) {
+	return c.run(ctx, takeScript, c.Key(), c.Capacity(), value, value > c.Capacity())
+}
+
+//Wait 阻塞直到注水成功或ctx结束
+//注水失败时等待当前窗口结束后重试,注水量不小于容量时返回limiterhelper.ErrWaitNeverAllowed
+func (c *Limiter) Wait(ctx context.Context, value int64) error {
+	return limiterhelper.Wait(ctx, c.Take, value)
+}
+
 //Flood 灌注
 //当返回为true说明注水成功,false表示无法注入(满了)
 func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
-	// if c.opt.MaxTTL != 0 {
-	// 	defer c.RefreshTTL(ctx)
-	// }
-	first := false
-	flag, err := c.Client().Exists(ctx, c.Key()).Result()
+	res, err := c.Take(ctx, value)
 	if err != nil {
 		return true, err
 	}
-	if flag != 0 {
-		first = true
-	}
-	res, err := c.Client().IncrBy(ctx, c.Key(), value).Result()
+	c.LimiterABC.CheckWaterline(res.WaterLevel(), !res.Allowed)
+	return res.Allowed, nil
+}
+
+//TryTake 注水并返回详细结果
+//注水规则与Take一致,但无法注入时不会占用容量
+func (c *Limiter) TryTake(ctx context.Context, value int64) (*limiterhelper.Reservation, error) {
+	return c.run(ctx, takeKeyScript, c.Key(), c.Capacity(), value, value > c.Capacity())
+}
+
+//TakeKey 对指定键注水并返回详细结果,窗口时长为MaxTTL
+//与Take不同,无法注入时不会占用容量
+//@params key string 计数使用的键
+//@params maxsize int64 窗口内的最大容量
+//@params value int64 注水量
+func (c *Limiter) TakeKey(ctx context.Context, key string, maxsize int64, value int64) (*limiterhelper.Reservation, error) {
+	return c.run(ctx, takeKeyScript, key, maxsize, value, value > maxsize)
+}
+
+//FloodKey 对指定键注水,窗口时长为MaxTTL
+//当返回为true说明注水成功,false表示无法注入(满了),无法注入时不会占用容量
+//@params key string 计数使用的键
+//@params maxsize int64 窗口内的最大容量
+//@params value int64 注水量
+func (c *Limiter) FloodKey(ctx context.Context, key string, maxsize int64, value int64) (bool, error) {
+	res, err := c.TakeKey(ctx, key, maxsize, value)
 	if err != nil {
 		return true, err
 	}
-	if first && c.MaxTTL() != 0 {
-		_, err := c.Client().Expire(ctx, c.Key(), c.MaxTTL()).Result()
-		if err != nil {
-			return true, err
-		}
-	}
-	full := c.LimiterABC.CheckWaterline(res, false)
-	if full {
-		diff := c.Capacity() - res
-		if diff < 0 {
-			go c.Client().IncrBy(ctx, c.Key(), diff).Result()
-		}
+	return res.Allowed, nil
+}
+
+//Refund 归还注水量,用于归还预先获取但没有用完的配额,当前窗口已经结束时不归还
+func (c *Limiter) Refund(ctx context.Context, value int64) error {
+	_, err := refundScript.Run(ctx, c.Client(), []string{c.Key()}, value).Result()
+	if err != nil {
+		return err
 	}
-	return !full, nil
+	return nil
 }
 
 //WaterLevel 当前水位
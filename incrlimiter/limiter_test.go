@@ -104,7 +104,7 @@ func Test_limiter_canExp(t *testing.T) {
 		assert.Equal(t, true, res)
 	}
 	for i := 0; i < 9; i++ {
-		res, err := limiter.Flood(ctx, 1)
+		res, err := limiter.Flood(ctx, 2)
 		if err != nil {
 			assert.FailNow(t, err.Error(), "limiter Flood get error")
 		}
@@ -119,7 +119,7 @@ func Test_limiter_canExp(t *testing.T) {
 		assert.Equal(t, true, res)
 	}
 	for i := 0; i < 9; i++ {
-		res, err := limiter.Flood(ctx, 1)
+		res, err := limiter.Flood(ctx, 2)
 		if err != nil {
 			assert.FailNow(t, err.Error(), "limiter Flood get error")
 		}
@@ -153,3 +153,137 @@ func Test_limiter_hooks(t *testing.T) {
 	}
 	time.Sleep(time.Second)
 }
+
+func Test_limiter_take(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(2*time.Second), WithMaxSize(10), WithWarningSize(5), WithSpecifiedKey("test_incrlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	var _ limiterhelper.TakerInterface = limiter
+	res, err := limiter.Take(ctx, 4)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, true, res.Allowed)
+	assert.Equal(t, int64(10), res.Limit)
+	assert.Equal(t, int64(6), res.Remaining)
+	assert.Equal(t, time.Duration(0), res.RetryAfter)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), res.ResetAt, 100*time.Millisecond)
+	res, err = limiter.Take(ctx, 8)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, false, res.Allowed)
+	assert.Equal(t, int64(0), res.Remaining)
+	assert.Greater(t, res.RetryAfter, time.Second)
+	assert.LessOrEqual(t, res.RetryAfter, 2*time.Second)
+	res, err = limiter.Take(ctx, 11)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, time.Duration(-1), res.RetryAfter)
+}
+
+func Test_limiter_take_boundary(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(5*time.Second), WithMaxSize(3), WithWarningSize(2), WithSpecifiedKey("test_incrlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	//三个入口使用相同的注水规则,剩余容量为0时下一次注水一定失败
+	takes := map[string]func() (*limiterhelper.Reservation, error){
+		"Take": func() (*limiterhelper.Reservation, error) {
+			return limiter.Take(ctx, 1)
+		},
+		"TryTake": func() (*limiterhelper.Reservation, error) {
+			return limiter.TryTake(ctx, 1)
+		},
+		"TakeKey": func() (*limiterhelper.Reservation, error) {
+			return limiter.TakeKey(ctx, limiter.Key(), 3, 1)
+		},
+	}
+	for name, take := range takes {
+		err = limiter.Reset(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Reset get error")
+		}
+		for i := int64(1); i <= 3; i++ {
+			res, err := take()
+			if err != nil {
+				assert.FailNow(t, err.Error(), "limiter take get error")
+			}
+			assert.Equal(t, true, res.Allowed, name)
+			assert.Equal(t, 3-i, res.Remaining, name)
+		}
+		res, err := take()
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter take get error")
+		}
+		assert.Equal(t, false, res.Allowed, name)
+		assert.Equal(t, int64(0), res.Remaining, name)
+		isfull, err := limiter.IsFull(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter IsFull get error")
+		}
+		assert.Equal(t, true, isfull, name)
+	}
+}
+
+func Test_limiter_take_key(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(2*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	var _ limiterhelper.KeyedFlooderInterface = limiter
+	res, err := limiter.TakeKey(ctx, "test_incrlimiter_key", 3, 3)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter TakeKey get error")
+	}
+	assert.Equal(t, true, res.Allowed)
+	assert.Equal(t, int64(0), res.Remaining)
+	res, err = limiter.TakeKey(ctx, "test_incrlimiter_key", 3, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter TakeKey get error")
+	}
+	assert.Equal(t, false, res.Allowed)
+	assert.Greater(t, res.RetryAfter, time.Duration(0))
+	time.Sleep(res.RetryAfter + 100*time.Millisecond)
+	ok, err := limiter.FloodKey(ctx, "test_incrlimiter_key", 3, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter FloodKey get error")
+	}
+	assert.Equal(t, true, ok)
+}
+
+func Test_limiter_wait(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck, WithMaxTTL(time.Second), WithMaxSize(10), WithWarningSize(5), WithSpecifiedKey("test_incrlimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		err := limiter.Wait(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Wait get error")
+		}
+	}
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	//窗口已满,等待窗口结束
+	err = limiter.Wait(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Wait get error")
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	limiter.Flood(tctx, 9)
+	err = limiter.Wait(tctx, 1)
+	assert.Equal(t, limiterhelper.ErrWaitExceedsDeadline, err)
+}
@@ -78,3 +78,14 @@ func WithMaxSize(maxsize int64) optparams.Option[Options] {
 func WithWarningSize(warningSize int64) optparams.Option[Options] {
 	return l(limiterhelper.WithWarningSize(warningSize))
 }
+
+//WithAsyncHooks 设置异步执行钩子,队列满时新触发的钩子会被丢弃
+//@params queueSize int 异步执行钩子的队列长度,必须大于0
+func WithAsyncHooks(queueSize int) optparams.Option[Options] {
+	return l(limiterhelper.WithAsyncHooks(queueSize))
+}
+
+//WithHookErrorHandler 设置钩子返回错误或panic时的处理函数,默认打印警告日志
+func WithHookErrorHandler(fn limiterhelper.HookErrorHandler) optparams.Option[Options] {
+	return l(limiterhelper.WithHookErrorHandler(fn))
+}
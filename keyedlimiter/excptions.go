@@ -0,0 +1,11 @@
+package keyedlimiter
+
+import (
+	"errors"
+)
+
+//ErrQuotaMustLargerThanZero 配额必须大于0
+var ErrQuotaMustLargerThanZero = errors.New("quota must larger than 0")
+
+//ErrSubjectEmpty 主体不能为空
+var ErrSubjectEmpty = errors.New("subject can not be empty")
@@ -0,0 +1,184 @@
+//Package keyedlimiter 按主体限流的限制器
+//一个限制器对象可以为任意多个主体(用户,ip等)限流,每个主体使用限制器的键加上主体作为键
+//具体的限流算法由实现了limiterhelper.KeyedFlooderInterface的限制器提供,包括incrlimiter,celllimiter,gcralimiter和slidinglimiter
+//主体的配额默认使用DefaultQuota,可以在redis的哈希表中为单个主体设置配额覆盖默认值
+package keyedlimiter
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//Limiter 按主体限流的限制器
+type Limiter struct {
+	opt Options
+	*middlewarehelper.MiddleWareAbc
+	flooder  limiterhelper.KeyedFlooderInterface
+	lock     sync.Mutex
+	quotas   map[string]int64
+	loadedAt time.Time
+}
+
+//New 创建一个按主体限流的限制器
+//@params cli redis.UniversalClient 客户端对象
+//@params flooder limiterhelper.KeyedFlooderInterface 提供限流算法的限制器
+//@params opts ...optparams.Option[Options] 限制器的可设置项
+func New(cli redis.UniversalClient, flooder limiterhelper.KeyedFlooderInterface, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k := new(Limiter)
+	k.opt = defaultOptions
+	optparams.GetOption(&k.opt, opts...)
+	m, err := middlewarehelper.New(cli, "limiter", k.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.MiddleWareAbc = m
+	k.flooder = flooder
+	return k, nil
+}
+
+//SubjectKey 主体使用的键
+//@params subject string 主体
+func (c *Limiter) SubjectKey(subject string) string {
+	return c.Key() + "::" + subject
+}
+
+//QuotaKey 保存主体配额的哈希表使用的键
+func (c *Limiter) QuotaKey() string {
+	if c.opt.QuotaKey != "" {
+		return c.opt.QuotaKey
+	}
+	return c.Key() + "::quota"
+}
+
+//loadQuotas 读取配额表
+func (c *Limiter) loadQuotas(ctx context.Context) (map[string]int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.quotas != nil && time.Since(c.loadedAt) < c.opt.QuotaCacheTTL {
+		return c.quotas, nil
+	}
+	res, err := c.Client().HGetAll(ctx, c.QuotaKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]int64, len(res))
+	for subject, v := range res {
+		quota, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.Logger().Warn("parse subject quota get error", map[string]any{"subject": subject, "err": err.Error()})
+			continue
+		}
+		quotas[subject] = quota
+	}
+	c.quotas = quotas
+	c.loadedAt = time.Now()
+	return quotas, nil
+}
+
+//Quota 查看主体的配额
+//@params subject string 主体
+func (c *Limiter) Quota(ctx context.Context, subject string) (int64, error) {
+	if c.opt.QuotaCacheTTL <= 0 {
+		quota, err := c.Client().HGet(ctx, c.QuotaKey(), subject).Int64()
+		if err != nil {
+			if err == redis.Nil {
+				return c.opt.DefaultQuota, nil
+			}
+			return 0, err
+		}
+		return quota, nil
+	}
+	quotas, err := c.loadQuotas(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if quota, ok := quotas[subject]; ok {
+		return quota, nil
+	}
+	return c.opt.DefaultQuota, nil
+}
+
+//SetQuota 设置主体的配额
+//@params subject string 主体
+//@params quota int64 配额,必须大于0
+func (c *Limiter) SetQuota(ctx context.Context, subject string, quota int64) error {
+	if quota <= 0 {
+		return ErrQuotaMustLargerThanZero
+	}
+	_, err := c.Client().HSet(ctx, c.QuotaKey(), subject, quota).Result()
+	if err != nil {
+		return err
+	}
+	c.expireQuotas()
+	return nil
+}
+
+//DeleteQuota 删除主体的配额,之后主体使用默认配额
+//@params subject string 主体
+func (c *Limiter) DeleteQuota(ctx context.Context, subject string) error {
+	_, err := c.Client().HDel(ctx, c.QuotaKey(), subject).Result()
+	if err != nil {
+		return err
+	}
+	c.expireQuotas()
+	return nil
+}
+
+//expireQuotas 使本地缓存的配额表失效,其他进程中的缓存依然会在QuotaCacheTTL后才失效
+func (c *Limiter) expireQuotas() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.quotas = nil
+}
+
+//Take 为主体注水并返回详细结果
+//@params subject string 主体
+//@params n int64 注水量
+func (c *Limiter) Take(ctx context.Context, subject string, n int64) (*limiterhelper.Reservation, error) {
+	if subject == "" {
+		return nil, ErrSubjectEmpty
+	}
+	quota, err := c.Quota(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	return c.flooder.TakeKey(ctx, c.SubjectKey(subject), quota, n)
+}
+
+//Allow 为主体注水
+//当返回为true说明注水成功,false表示无法注入(满了),出错时返回true
+//@params subject string 主体
+//@params n int64 注水量
+func (c *Limiter) Allow(ctx context.Context, subject string, n int64) (bool, error) {
+	res, err := c.Take(ctx, subject, n)
+	if err != nil {
+		return true, err
+	}
+	return res.Allowed, nil
+}
+
+//Wait 阻塞直到为主体注水成功或ctx结束
+//@params subject string 主体
+//@params n int64 注水量
+func (c *Limiter) Wait(ctx context.Context, subject string, n int64) error {
+	return limiterhelper.Wait(ctx, func(ctx context.Context, n int64) (*limiterhelper.Reservation, error) {
+		return c.Take(ctx, subject, n)
+	}, n)
+}
+
+//Reset 重置主体的限流状态
+//@params subject string 主体
+func (c *Limiter) Reset(ctx context.Context, subject string) error {
+	_, err := c.Client().Del(ctx, c.SubjectKey(subject)).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package keyedlimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/exthelper/cellhelper/celllimiter"
+	"github.com/Golang-Tools/redishelper/v2/gcralimiter"
+	"github.com/Golang-Tools/redishelper/v2/incrlimiter"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/slidinglimiter"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+func NewFlooders(t *testing.T, cli redis.UniversalClient) map[string]limiterhelper.KeyedFlooderInterface {
+	incr, err := incrlimiter.New(cli, incrlimiter.WithMaxTTL(5*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	sliding, err := slidinglimiter.NewLog(cli, slidinglimiter.WithWindow(5*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "slidinglimiter new get error")
+	}
+	gcra, err := gcralimiter.New(cli, gcralimiter.WithDefaultCountPerPeriod(1), gcralimiter.WithDefaultPeriod(5))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "gcralimiter new get error")
+	}
+	return map[string]limiterhelper.KeyedFlooderInterface{
+		"incr":    incr,
+		"sliding": sliding,
+		"gcra":    gcra,
+	}
+}
+
+var _ limiterhelper.KeyedFlooderInterface = (*celllimiter.Limiter)(nil)
+
+func Test_keyed_limiter(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	for name, flooder := range NewFlooders(t, cli) {
+		limiter, err := New(cli, flooder, WithKey("test_keyedlimiter_"+name), WithDefaultQuota(3))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter new get error")
+		}
+		assert.Equal(t, "redishelper::limiter::test_keyedlimiter_"+name+"::alice", limiter.SubjectKey("alice"), name)
+		_, err = limiter.Allow(ctx, "", 1)
+		assert.Equal(t, ErrSubjectEmpty, err, name)
+		err = limiter.SetQuota(ctx, "alice", 5)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter SetQuota get error")
+		}
+		for subject, quota := range map[string]int{"alice": 5, "bob": 3} {
+			for i := 0; i < quota; i++ {
+				ok, err := limiter.Allow(ctx, subject, 1)
+				if err != nil {
+					assert.FailNow(t, err.Error(), "limiter Allow get error")
+				}
+				assert.Equal(t, true, ok, name, subject)
+			}
+			ok, err := limiter.Allow(ctx, subject, 1)
+			if err != nil {
+				assert.FailNow(t, err.Error(), "limiter Allow get error")
+			}
+			assert.Equal(t, false, ok, name, subject)
+		}
+		res, err := limiter.Take(ctx, "alice", 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Take get error")
+		}
+		assert.Equal(t, false, res.Allowed, name)
+		assert.Equal(t, int64(5), res.Limit, name)
+		assert.Greater(t, res.RetryAfter, time.Duration(0), name)
+		//主体之间互不影响
+		ok, err := limiter.Allow(ctx, "carol", 3)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Allow get error")
+		}
+		assert.Equal(t, true, ok, name)
+		err = limiter.Reset(ctx, "bob")
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Reset get error")
+		}
+		ok, err = limiter.Allow(ctx, "bob", 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Allow get error")
+		}
+		assert.Equal(t, true, ok, name)
+	}
+}
+
+func Test_keyed_limiter_quota(t *testing.T) {
+	cli, ctx := NewBackgroundClient(t)
+	defer cli.Close()
+	incr, err := incrlimiter.New(cli, incrlimiter.WithMaxTTL(5*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "incrlimiter new get error")
+	}
+	limiter, err := New(cli, incr, WithKey("test_keyedlimiter"), WithQuotaKey("test_keyedlimiter_quota"), WithQuotaCacheTTL(200*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	assert.Equal(t, "test_keyedlimiter_quota", limiter.QuotaKey())
+	assert.Equal(t, ErrQuotaMustLargerThanZero, limiter.SetQuota(ctx, "alice", 0))
+	quota, err := limiter.Quota(ctx, "alice")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Quota get error")
+	}
+	assert.Equal(t, int64(100), quota)
+	//其他进程设置的配额在缓存过期后生效
+	cli.HSet(ctx, "test_keyedlimiter_quota", "alice", 10)
+	quota, err = limiter.Quota(ctx, "alice")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Quota get error")
+	}
+	assert.Equal(t, int64(100), quota)
+	time.Sleep(300 * time.Millisecond)
+	quota, err = limiter.Quota(ctx, "alice")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Quota get error")
+	}
+	assert.Equal(t, int64(10), quota)
+	err = limiter.DeleteQuota(ctx, "alice")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter DeleteQuota get error")
+	}
+	quota, err = limiter.Quota(ctx, "alice")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Quota get error")
+	}
+	assert.Equal(t, int64(100), quota)
+}
@@ -0,0 +1,69 @@
+package keyedlimiter
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+)
+
+type Options struct {
+	DefaultQuota   int64                                        //未设置配额的主体使用的配额
+	QuotaKey       string                                       //保存主体配额的哈希表使用的键,为空时使用限制器的键加上后缀::quota
+	QuotaCacheTTL  time.Duration                                //本地缓存配额表的时长,为0时每次都从redis中读取
+	MiddlewareOpts []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+}
+
+var defaultOptions = Options{
+	DefaultQuota:   100,
+	QuotaCacheTTL:  10 * time.Second,
+	MiddlewareOpts: []optparams.Option[middlewarehelper.Options]{},
+}
+
+//WithDefaultQuota 设置未设置配额的主体使用的配额,默认100,必须大于0
+func WithDefaultQuota(quota int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if quota > 0 {
+			o.DefaultQuota = quota
+		}
+	})
+}
+
+//WithQuotaKey 设置保存主体配额的哈希表使用的键
+func WithQuotaKey(key string) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.QuotaKey = key
+	})
+}
+
+//WithQuotaCacheTTL 设置本地缓存配额表的时长,默认10s,设置为0时每次都从redis中读取
+func WithQuotaCacheTTL(ttl time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.QuotaCacheTTL = ttl
+	})
+}
+
+//m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
+func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.MiddlewareOpts == nil {
+			o.MiddlewareOpts = []optparams.Option[middlewarehelper.Options]{}
+		}
+		o.MiddlewareOpts = append(o.MiddlewareOpts, opts...)
+	})
+}
+
+//WithSpecifiedKey 中间件通用设置,指定使用的键,注意设置key后namespace将失效
+func WithSpecifiedKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithSpecifiedKey(key))
+}
+
+//WithKey 中间件通用设置,指定使用的键,注意设置后namespace依然有效
+func WithKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithKey(key))
+}
+
+//WithNamespace 中间件通用设置,指定锁的命名空间
+func WithNamespace(ns ...string) optparams.Option[Options] {
+	return m(middlewarehelper.WithNamespace(ns...))
+}
@@ -41,13 +41,20 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Keyspac
 //Start 监听事件
 //@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
 func (s *KeyspaceNotification) Start(opts ...optparams.Option[pchelper.ListenOptions]) error {
+	return s.StartWithContext(context.Background(), opts...)
+}
+
+//StartWithContext 监听事件,ctx结束或调用Stop时停止监听
+//@params ctx context.Context 监听的上下文
+//@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
+func (s *KeyspaceNotification) StartWithContext(ctx context.Context, opts ...optparams.Option[pchelper.ListenOptions]) error {
 	_topics := []string{}
 	for topic := range s.opt.NotificationEventHandlers {
 		_topics = append(_topics, topic)
 	}
 	topics := strings.Join(_topics, ",")
 	logger.Info("Start Listening", map[string]any{"topics": topics})
-	return s.csm.Listen(topics, opts...)
+	return s.csm.ListenWithContext(ctx, topics, opts...)
 }
 
 //Stop 停止监听
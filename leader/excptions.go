@@ -0,0 +1,14 @@
+package leader
+
+import (
+	"errors"
+)
+
+//ErrNeedMaxTTL 领导者选举必须设置MaxTTL作为租约时长
+var ErrNeedMaxTTL = errors.New("leader election must set MaxTTL")
+
+//ErrRenewIntervalTooLong 续约间隔必须小于MaxTTL
+var ErrRenewIntervalTooLong = errors.New("renew interval must less than MaxTTL")
+
+//ErrNotLeader 当前客户端不是领导者
+var ErrNotLeader = errors.New("not leader")
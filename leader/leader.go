@@ -0,0 +1,308 @@
+//Package leader 基于redis的领导者选举
+//竞选者通过设置带过期时间的键竞选领导者,当选后定时续约,续约和退位使用lock模块中校验持有者的脚本,
+//领导者变更时会在频道中广播新领导者的客户端id,领导者退位时广播空字符串
+package leader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/lock"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//Callback 领导权变化时的回调函数
+type Callback func()
+
+//Leader 领导者选举对象
+type Leader struct {
+	opt Options
+	*middlewarehelper.MiddleWareAbc
+	*clientIdhelper.ClientIDAbc
+	stateLock       sync.Mutex
+	isLeader        bool
+	term            int64
+	renewCancel     context.CancelFunc
+	callbacksLock   sync.RWMutex
+	electedCallback []Callback
+	revokedCallback []Callback
+}
+
+//New 新建一个领导者选举对象,必须设置MaxTTL作为领导者租约的时长
+func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Leader, error) {
+	l := new(Leader)
+	l.opt = defaultOptions
+	optparams.GetOption(&l.opt, opts...)
+	meta, err := middlewarehelper.New(cli, "leader", l.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if meta.MaxTTL() <= 0 {
+		return nil, ErrNeedMaxTTL
+	}
+	if l.opt.RenewInterval <= 0 {
+		l.opt.RenewInterval = meta.MaxTTL() / 3
+	}
+	if l.opt.RenewInterval >= meta.MaxTTL() {
+		return nil, ErrRenewIntervalTooLong
+	}
+	if l.opt.RetryInterval <= 0 {
+		l.opt.RetryInterval = meta.MaxTTL() / 3
+	}
+	cid, err := clientIdhelper.New(l.opt.ClientIDOpts...)
+	if err != nil {
+		return nil, err
+	}
+	l.MiddleWareAbc = meta
+	l.ClientIDAbc = cid
+	return l, nil
+}
+
+//Channel 广播领导者变更的频道
+func (l *Leader) Channel() string {
+	return l.Key() + "::changed"
+}
+
+//OnElected 注册当选领导者时的回调,回调会在独立的goroutine中执行
+func (l *Leader) OnElected(fn Callback) {
+	l.callbacksLock.Lock()
+	defer l.callbacksLock.Unlock()
+	l.electedCallback = append(l.electedCallback, fn)
+}
+
+//OnRevoked 注册失去领导权时的回调,回调会在独立的goroutine中执行
+func (l *Leader) OnRevoked(fn Callback) {
+	l.callbacksLock.Lock()
+	defer l.callbacksLock.Unlock()
+	l.revokedCallback = append(l.revokedCallback, fn)
+}
+
+//fire 执行回调
+func (l *Leader) fire(elected bool) {
+	l.callbacksLock.RLock()
+	defer l.callbacksLock.RUnlock()
+	callbacks := l.revokedCallback
+	if elected {
+		callbacks = l.electedCallback
+	}
+	for _, fn := range callbacks {
+		go fn()
+	}
+}
+
+//publish 广播领导者变更
+func (l *Leader) publish(ctx context.Context, leader string) {
+	_, err := l.Client().Publish(ctx, l.Channel(), leader).Result()
+	if err != nil {
+		l.Logger().Warn("publish leader changed get error", log.Dict{"err": err.Error()})
+	}
+}
+
+//写操作
+
+//Campaign 竞选领导者,阻塞直到当选或ctx结束
+//竞选失败时等待领导者退位的广播或最多等待RetryInterval后重试
+func (l *Leader) Campaign(ctx context.Context) error {
+	if l.IsLeader() {
+		return nil
+	}
+	pubsub := l.Client().Subscribe(ctx, l.Channel())
+	defer pubsub.Close()
+	notify := pubsub.Channel()
+	for {
+		elected, err := l.tryElect(ctx)
+		if err != nil {
+			return err
+		}
+		if elected {
+			return nil
+		}
+		timer := time.NewTimer(l.opt.RetryInterval)
+		select {
+		case <-ctx.Done():
+			{
+				timer.Stop()
+				return ctx.Err()
+			}
+		case <-notify:
+			{
+				timer.Stop()
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+//tryElect 尝试竞选一次
+//领导者键已经属于当前客户端时(比如进程重启后使用相同的客户端id)直接续约并视为当选
+func (l *Leader) tryElect(ctx context.Context) (bool, error) {
+	start := time.Now()
+	set, err := l.Client().SetNX(ctx, l.Key(), l.ClientID(), l.MaxTTL()).Result()
+	if err != nil {
+		return false, err
+	}
+	if !set {
+		res, err := lock.ExtendScript.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID(), l.MaxTTL().Milliseconds()).Int64()
+		if err != nil {
+			return false, err
+		}
+		if res == 0 {
+			return false, nil
+		}
+	}
+	l.elected(ctx, start)
+	return true, nil
+}
+
+//elected 当选后启动续约任务,广播并执行回调
+//@params start time.Time 发起竞选请求的时间,租约从这个时间开始计算
+func (l *Leader) elected(ctx context.Context, start time.Time) {
+	l.stateLock.Lock()
+	if l.isLeader {
+		l.stateLock.Unlock()
+		return
+	}
+	l.isLeader = true
+	l.term++
+	renewctx, cancel := context.WithCancel(context.Background())
+	l.renewCancel = cancel
+	go l.renew(renewctx, l.term, start)
+	l.stateLock.Unlock()
+	l.publish(ctx, l.ClientID())
+	l.fire(true)
+}
+
+//revoke 失去领导权,停止续约任务并执行回调
+//@params term int64 失去的任期,与当前任期不一致时不做处理
+func (l *Leader) revoke(term int64) bool {
+	l.stateLock.Lock()
+	if !l.isLeader || l.term != term {
+		l.stateLock.Unlock()
+		return false
+	}
+	l.isLeader = false
+	l.renewCancel()
+	l.renewCancel = nil
+	l.stateLock.Unlock()
+	l.fire(false)
+	return true
+}
+
+//renew 领导者的续约循环
+//续约时发现键已不属于当前客户端时失去领导权
+//租约从发起最近一次成功续约的请求时开始计算,在下一次续约之前就可能过期(即距离上次成功续约超过MaxTTL-RenewInterval)时主动退位,
+//保证旧领导者在租约过期,新领导者可能当选之前失去领导权
+//@params lastRenew time.Time 发起竞选请求的时间
+func (l *Leader) renew(ctx context.Context, term int64, lastRenew time.Time) {
+	ticker := time.NewTicker(l.opt.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-ticker.C:
+			{
+				start := time.Now()
+				ctx1, cancel := context.WithTimeout(ctx, l.opt.RenewInterval)
+				res, err := lock.ExtendScript.Run(ctx1, l.Client(), []string{l.Key()}, l.ClientID(), l.MaxTTL().Milliseconds()).Int64()
+				cancel()
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					l.Logger().Error("renew leader lease get error", log.Dict{"err": err.Error()})
+					if time.Since(lastRenew) >= l.MaxTTL()-l.opt.RenewInterval {
+						l.Logger().Warn("leader lease expired")
+						l.revoke(term)
+						return
+					}
+					continue
+				}
+				if res == 0 {
+					l.Logger().Warn("leader lease lost")
+					l.revoke(term)
+					return
+				}
+				lastRenew = start
+			}
+		}
+	}
+}
+
+//Resign 主动退位,释放领导者键并广播,当前客户端不是领导者时返回ErrNotLeader
+func (l *Leader) Resign(ctx context.Context) error {
+	l.stateLock.Lock()
+	term := l.term
+	l.stateLock.Unlock()
+	l.revoke(term)
+	res, err := lock.UnlockScript.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrNotLeader
+	}
+	l.publish(ctx, "")
+	return nil
+}
+
+//读操作
+
+//IsLeader 当前客户端是否为领导者
+func (l *Leader) IsLeader() bool {
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+	return l.isLeader
+}
+
+//Leader 查看当前领导者的客户端id,没有领导者时返回空字符串
+func (l *Leader) Leader(ctx context.Context) (string, error) {
+	res, err := l.Client().Get(ctx, l.Key()).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", err
+	}
+	return res, nil
+}
+
+//Observe 监听领导者变更,返回的chan中为新领导者的客户端id,空字符串表示领导者已退位,ctx结束时chan关闭
+func (l *Leader) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	pubsub := l.Client().Subscribe(ctx, l.Channel())
+	go func() {
+		defer close(ch)
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				{
+					return
+				}
+			case msg, ok := <-msgs:
+				{
+					if !ok {
+						return
+					}
+					select {
+					case ch <- msg.Payload:
+					case <-ctx.Done():
+						{
+							return
+						}
+					}
+				}
+			}
+		}
+	}()
+	return ch
+}
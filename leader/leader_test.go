@@ -0,0 +1,170 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+func Test_new_Leader_err(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	_, err := New(ck)
+	assert.Equal(t, ErrNeedMaxTTL, err)
+	_, err = New(ck, WithMaxTTL(time.Second), WithRenewInterval(time.Second))
+	assert.Equal(t, ErrRenewIntervalTooLong, err)
+	l, err := New(ck, WithMaxTTL(time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new leader error")
+	}
+	assert.Equal(t, true, strings.HasPrefix(l.Key(), "redishelper::leader::"))
+}
+
+func Test_Leader_Campaign(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	key := "test_leader"
+	l1, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(2*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new leader1 error")
+	}
+	l2, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(2*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new leader2 error")
+	}
+	var elected, revoked int32
+	l1.OnElected(func() { atomic.AddInt32(&elected, 1) })
+	l1.OnRevoked(func() { atomic.AddInt32(&revoked, 1) })
+	obctx, obcancel := context.WithCancel(ctx)
+	defer obcancel()
+	changes := l2.Observe(obctx)
+	time.Sleep(100 * time.Millisecond)
+
+	err = l1.Campaign(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "leader1 campaign error")
+	}
+	assert.Equal(t, true, l1.IsLeader())
+	assert.Equal(t, "client01", <-changes)
+
+	//续约使领导权保持超过MaxTTL
+	ctx1, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	err = l2.Campaign(ctx1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, false, l2.IsLeader())
+	leader, err := l2.Leader(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get leader error")
+	}
+	assert.Equal(t, "client01", leader)
+
+	done := make(chan error)
+	go func() {
+		done <- l2.Campaign(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	err = l1.Resign(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "leader1 resign error")
+	}
+	assert.Equal(t, false, l1.IsLeader())
+	assert.Equal(t, "", <-changes)
+	err = <-done
+	if err != nil {
+		assert.FailNow(t, err.Error(), "leader2 campaign error")
+	}
+	assert.Equal(t, true, l2.IsLeader())
+	assert.Equal(t, "client02", <-changes)
+	err = l1.Resign(ctx)
+	assert.Equal(t, ErrNotLeader, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&elected))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&revoked))
+}
+
+//blockHook 阻塞续约请求的钩子
+type blockHook struct {
+	blocked int32
+}
+
+func (h *blockHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if atomic.LoadInt32(&h.blocked) == 1 && strings.HasPrefix(cmd.Name(), "eval") {
+		return ctx, errors.New("blocked")
+	}
+	return ctx, nil
+}
+
+func (h *blockHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *blockHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h *blockHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func Test_Leader_step_down_when_renew_blocked(t *testing.T) {
+	ck1, ctx := NewBackgroundClient(t)
+	defer ck1.Close()
+	ck2, _ := NewBackgroundClient(t)
+	defer ck2.Close()
+	hook := &blockHook{}
+	ck1.AddHook(hook)
+	key := "test_leader"
+	l1, err := New(ck1, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(time.Second), WithRenewInterval(200*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new leader1 error")
+	}
+	l2, err := New(ck2, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(time.Second), WithRetryInterval(20*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new leader2 error")
+	}
+	var revokedAt, electedAt int64
+	l1.OnRevoked(func() { atomic.StoreInt64(&revokedAt, time.Now().UnixNano()) })
+	err = l1.Campaign(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "leader1 campaign error")
+	}
+	atomic.StoreInt32(&hook.blocked, 1)
+	ctx1, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	err = l2.Campaign(ctx1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "leader2 campaign error")
+	}
+	electedAt = time.Now().UnixNano()
+	time.Sleep(50 * time.Millisecond)
+	//旧领导者在新领导者当选之前退位
+	assert.Equal(t, false, l1.IsLeader())
+	assert.NotZero(t, atomic.LoadInt64(&revokedAt))
+	assert.Less(t, atomic.LoadInt64(&revokedAt), electedAt)
+	assert.Equal(t, true, l2.IsLeader())
+	l2.Resign(ctx)
+}
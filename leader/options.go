@@ -0,0 +1,80 @@
+package leader
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+)
+
+type Options struct {
+	RenewInterval  time.Duration                                //领导者续约的间隔,为0时使用MaxTTL的1/3
+	RetryInterval  time.Duration                                //竞选失败后重试的最大间隔,为0时使用MaxTTL的1/3
+	MiddlewareOpts []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+	ClientIDOpts   []optparams.Option[clientIdhelper.Options]   //初始化clientID的配置
+}
+
+var defaultOptions = Options{
+	MiddlewareOpts: []optparams.Option[middlewarehelper.Options]{},
+	ClientIDOpts:   []optparams.Option[clientIdhelper.Options]{},
+}
+
+//WithRenewInterval 设置领导者续约的间隔,必须小于MaxTTL
+func WithRenewInterval(interval time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.RenewInterval = interval
+	})
+}
+
+//WithRetryInterval 设置竞选失败后重试的最大间隔,领导者主动退位时等待者会通过pubsub被立即唤醒
+func WithRetryInterval(interval time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.RetryInterval = interval
+	})
+}
+
+//c 使用optparams.Option[clientIdhelper.Options]设置客户端ID属性
+func c(opts ...optparams.Option[clientIdhelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.ClientIDOpts == nil {
+			o.ClientIDOpts = []optparams.Option[clientIdhelper.Options]{}
+		}
+		o.ClientIDOpts = append(o.ClientIDOpts, opts...)
+	})
+}
+
+//WithClientID 设置客户端id,作为竞选者的标识
+func WithClientID(key string) optparams.Option[Options] {
+	return c(clientIdhelper.WithClientID(key))
+}
+
+//m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
+func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.MiddlewareOpts == nil {
+			o.MiddlewareOpts = []optparams.Option[middlewarehelper.Options]{}
+		}
+		o.MiddlewareOpts = append(o.MiddlewareOpts, opts...)
+	})
+}
+
+//WithSpecifiedKey 中间件通用设置,指定使用的键,注意设置key后namespace将失效
+func WithSpecifiedKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithSpecifiedKey(key))
+}
+
+//WithKey 中间件通用设置,指定使用的键,注意设置后namespace依然有效
+func WithKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithKey(key))
+}
+
+//WithNamespace 中间件通用设置,指定锁的命名空间
+func WithNamespace(ns ...string) optparams.Option[Options] {
+	return m(middlewarehelper.WithNamespace(ns...))
+}
+
+//WithMaxTTL 设置领导者租约的时长,必须设置
+func WithMaxTTL(maxTTL time.Duration) optparams.Option[Options] {
+	return m(middlewarehelper.WithMaxTTL(maxTTL))
+}
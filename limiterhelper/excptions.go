@@ -8,4 +8,18 @@ import (
 var ErrLimiterMaxSizeMustLargerThanWaringSize = errors.New("limiter's maxsize must larger than waring size")
 
 //ErrAlreadyHasHook limiter的指定钩子已经设置过了
+//钩子已经支持注册多个,不会再返回这个错误,保留以兼容
 var ErrAlreadyHasHook = errors.New("already has hook")
+
+//ErrWaitNeverAllowed 注水量超过容量,等待也无法注水成功
+var ErrWaitNeverAllowed = errors.New("wait never allowed,value larger than capacity")
+
+//ErrWaitExceedsDeadline 需要等待的时间超过了ctx的截止时间
+var ErrWaitExceedsDeadline = errors.New("wait would exceed context deadline")
+
+//ErrHookQueueFull 异步执行钩子的队列已满,钩子被丢弃
+//交给错误处理函数的错误会包装这个错误并附带被丢弃的钩子数,需要使用errors.Is判断
+var ErrHookQueueFull = errors.New("hook queue full")
+
+//ErrRecoveredHookNotSupported 限制器不支持注册水位回落的钩子
+var ErrRecoveredHookNotSupported = errors.New("recovered hook not supported")
@@ -0,0 +1,111 @@
+//Package grpclimiter 使用限制器为grpc服务限流的拦截器
+//从请求中获取主体(ip,metadata,方法等)后调用限制器注水,被限流时返回ResourceExhausted状态并在详情中附带errdetails.RetryInfo
+//响应的header metadata中会设置x-ratelimit-limit,x-ratelimit-remaining和x-ratelimit-reset
+package grpclimiter
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+//Limiter grpc的限流拦截器
+type Limiter struct {
+	opt  Options
+	take limiterhelper.SubjectTakeFunc
+}
+
+//New 创建一个限流拦截器
+//@params take limiterhelper.SubjectTakeFunc 按主体注水的函数,可以使用keyedlimiter.Limiter的Take方法或者limiterhelper.SubjectTake包装的限制器
+//@params opts ...optparams.Option[Options] 拦截器的配置
+func New(take limiterhelper.SubjectTakeFunc, opts ...optparams.Option[Options]) *Limiter {
+	l := new(Limiter)
+	l.opt = defaultOptions
+	optparams.GetOption(&l.opt, opts...)
+	l.take = take
+	return l
+}
+
+//check 注水并返回拦截请求时使用的错误,放行时返回nil
+//主体为空时使用备用主体,没有备用主体时返回InvalidArgument
+func (l *Limiter) check(ctx context.Context, fullMethod string, setHeader func(metadata.MD) error) error {
+	subject := l.opt.Subject(ctx, fullMethod)
+	if subject == "" && l.opt.Fallback != nil {
+		subject = l.opt.Fallback(ctx, fullMethod)
+	}
+	if subject == "" {
+		return status.Error(codes.InvalidArgument, "rate limit subject not found")
+	}
+	res, err := l.take(ctx, subject, l.opt.Cost)
+	if err != nil {
+		log.Warn("grpc limiter take get error", log.Dict{"subject": subject, "method": fullMethod, "err": err.Error()})
+		if l.opt.FailOpen {
+			return nil
+		}
+		return status.Error(codes.Unavailable, "rate limiter unavailable")
+	}
+	if l.opt.Headers {
+		md := metadata.Pairs("x-ratelimit-limit", strconv.FormatInt(res.Limit, 10), "x-ratelimit-remaining", strconv.FormatInt(res.Remaining, 10))
+		if !res.ResetAt.IsZero() {
+			md.Set("x-ratelimit-reset", strconv.FormatInt(seconds(time.Until(res.ResetAt)), 10))
+		}
+		err := setHeader(md)
+		if err != nil {
+			log.Warn("grpc limiter set header get error", log.Dict{"method": fullMethod, "err": err.Error()})
+		}
+	}
+	if res.Allowed {
+		return nil
+	}
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+	if res.RetryAfter > 0 {
+		detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+		if err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+//UnaryServerInterceptor 一元请求的拦截器
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		err := l.check(ctx, info.FullMethod, func(md metadata.MD) error {
+			return grpc.SetHeader(ctx, md)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+//StreamServerInterceptor 流式请求的拦截器,只在流建立时注水一次
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := l.check(ss.Context(), info.FullMethod, ss.SetHeader)
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+//seconds 向上取整的秒数,不小于0
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
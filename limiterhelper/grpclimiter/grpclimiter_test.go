@@ -0,0 +1,112 @@
+package grpclimiter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//counterTake 每个主体最多注水limit次的测试用注水函数
+func counterTake(limit int64) limiterhelper.SubjectTakeFunc {
+	counts := map[string]int64{}
+	return func(ctx context.Context, subject string, n int64) (*limiterhelper.Reservation, error) {
+		level := counts[subject] + n
+		if level > limit {
+			return limiterhelper.NewReservation(false, limit, counts[subject], 1500*time.Millisecond, 2*time.Second), nil
+		}
+		counts[subject] = level
+		return limiterhelper.NewReservation(true, limit, level, 0, 2*time.Second), nil
+	}
+}
+
+//testStream 测试用的ServerStream
+type testStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func unaryHandler(ctx context.Context, req any) (any, error) {
+	return "ok", nil
+}
+
+func Test_unary_interceptor(t *testing.T) {
+	interceptor := New(counterTake(1)).UnaryServerInterceptor()
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	res, err := interceptor(ctx, nil, info, unaryHandler)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", res)
+	_, err = interceptor(ctx, nil, info, unaryHandler)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	details := st.Details()
+	if assert.Len(t, details, 1) {
+		assert.Equal(t, 1500*time.Millisecond, details[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+	}
+}
+
+func Test_stream_interceptor(t *testing.T) {
+	interceptor := New(counterTake(1), WithSubject(SubjectByMetadata("x-user"))).StreamServerInterceptor()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user", "u1"))
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}
+	handler := func(srv any, ss grpc.ServerStream) error {
+		return nil
+	}
+	ss := &testStream{ctx: ctx}
+	err := interceptor(nil, ss, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, ss.header.Get("x-ratelimit-limit"))
+	assert.Equal(t, []string{"0"}, ss.header.Get("x-ratelimit-remaining"))
+	err = interceptor(nil, &testStream{ctx: ctx}, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	err = interceptor(nil, &testStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user", "u2"))}, info, handler)
+	assert.Nil(t, err)
+}
+
+func Test_interceptor_fail(t *testing.T) {
+	take := func(ctx context.Context, subject string, n int64) (*limiterhelper.Reservation, error) {
+		return nil, errors.New("redis down")
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	_, err := New(take).UnaryServerInterceptor()(ctx, nil, info, unaryHandler)
+	assert.Nil(t, err)
+	_, err = New(take, WithFailClosed()).UnaryServerInterceptor()(ctx, nil, info, unaryHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "/test.Service/Method", SubjectByMethod()(context.Background(), info.FullMethod))
+}
+
+func Test_interceptor_empty_subject(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	//没有metadata时按peer限流,不会绕过限流
+	interceptor := New(counterTake(1), WithSubject(SubjectByMetadata("x-user"))).UnaryServerInterceptor()
+	_, err := interceptor(ctx, nil, info, unaryHandler)
+	assert.Nil(t, err)
+	_, err = interceptor(ctx, nil, info, unaryHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	interceptor = New(counterTake(1), WithSubject(SubjectByMetadata("x-user")), WithRejectEmptySubject()).UnaryServerInterceptor()
+	_, err = interceptor(ctx, nil, info, unaryHandler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
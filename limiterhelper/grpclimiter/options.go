@@ -0,0 +1,115 @@
+package grpclimiter
+
+import (
+	"context"
+	"net"
+
+	"github.com/Golang-Tools/optparams"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+//SubjectFunc 从请求中获取限流主体的函数
+//@params ctx context.Context 请求的上下文,可以从中获取metadata和peer
+//@params fullMethod string 请求的完整方法名,形如/package.Service/Method
+type SubjectFunc func(ctx context.Context, fullMethod string) string
+
+type Options struct {
+	Subject  SubjectFunc //获取限流主体的函数
+	Fallback SubjectFunc //主体为空时使用的主体,为nil或结果依然为空时拒绝请求并返回InvalidArgument
+	Cost     int64       //每个请求的注水量
+	FailOpen bool        //限制器出错时是否放行请求
+	Headers  bool        //是否在响应的header metadata中设置x-ratelimit-*
+}
+
+var defaultOptions = Options{
+	Subject:  SubjectByPeer(),
+	Fallback: SubjectByPeer(),
+	Cost:     1,
+	FailOpen: true,
+	Headers:  true,
+}
+
+//WithSubject 设置获取限流主体的函数,默认使用SubjectByPeer()
+func WithSubject(fn SubjectFunc) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Subject = fn
+	})
+}
+
+//WithFallbackSubject 设置主体为空(比如请求没有带指定的metadata)时使用的主体,默认使用SubjectByPeer()
+//主体为空的请求不会绕过限流,它们会按这里的主体限流
+func WithFallbackSubject(fn SubjectFunc) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Fallback = fn
+	})
+}
+
+//WithRejectEmptySubject 设置主体为空时直接拒绝请求并返回InvalidArgument,不使用备用主体
+func WithRejectEmptySubject() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Fallback = nil
+	})
+}
+
+//WithCost 设置每个请求的注水量,默认1,流式请求只在建立时注水一次
+func WithCost(cost int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if cost > 0 {
+			o.Cost = cost
+		}
+	})
+}
+
+//WithFailClosed 设置限制器出错(比如redis不可用)时拒绝请求并返回Unavailable,默认放行请求
+func WithFailClosed() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.FailOpen = false
+	})
+}
+
+//WithoutHeaders 设置不在响应的header metadata中设置x-ratelimit-*
+func WithoutHeaders() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Headers = false
+	})
+}
+
+//SubjectByPeer 使用客户端ip作为主体
+func SubjectByPeer() SubjectFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ""
+		}
+		addr := p.Addr.String()
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr
+		}
+		return host
+	}
+}
+
+//SubjectByMetadata 使用请求metadata中的值作为主体,比如用户id,api key
+//@params key string metadata的键
+func SubjectByMetadata(key string) SubjectFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return ""
+		}
+		vals := md.Get(key)
+		if len(vals) == 0 {
+			return ""
+		}
+		return vals[0]
+	}
+}
+
+//SubjectByMethod 使用完整方法名作为主体,每个接口单独限流
+func SubjectByMethod() SubjectFunc {
+	return func(ctx context.Context, fullMethod string) string {
+		return fullMethod
+	}
+}
@@ -0,0 +1,89 @@
+//Package httplimiter 使用限制器为net/http服务限流的中间件
+//从请求中获取主体(ip,请求头,接口等)后调用限制器注水,被限流时返回429并设置Retry-After
+//注水成功时设置X-RateLimit-Limit,X-RateLimit-Remaining和X-RateLimit-Reset响应头
+package httplimiter
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+)
+
+//Limiter net/http的限流中间件
+type Limiter struct {
+	opt  Options
+	take limiterhelper.SubjectTakeFunc
+}
+
+//New 创建一个限流中间件
+//@params take limiterhelper.SubjectTakeFunc 按主体注水的函数,可以使用keyedlimiter.Limiter的Take方法或者limiterhelper.SubjectTake包装的限制器
+//@params opts ...optparams.Option[Options] 中间件的配置
+func New(take limiterhelper.SubjectTakeFunc, opts ...optparams.Option[Options]) *Limiter {
+	l := new(Limiter)
+	l.opt = defaultOptions
+	optparams.GetOption(&l.opt, opts...)
+	l.take = take
+	return l
+}
+
+//Middleware 包装处理函数,请求先经过限流再交给处理函数
+//主体为空时使用备用主体,没有备用主体时返回400;限制器出错时按配置放行请求或者返回503
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject := l.opt.Subject(r)
+		if subject == "" && l.opt.Fallback != nil {
+			subject = l.opt.Fallback(r)
+		}
+		if subject == "" {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		res, err := l.take(r.Context(), subject, l.opt.Cost)
+		if err != nil {
+			log.Warn("http limiter take get error", log.Dict{"subject": subject, "err": err.Error()})
+			if l.opt.FailOpen {
+				next.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		if l.opt.Headers {
+			setHeaders(w.Header(), res)
+		}
+		if res.Allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if res.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+		}
+		if l.opt.OnLimited != nil {
+			l.opt.OnLimited.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	})
+}
+
+//setHeaders 设置X-RateLimit-*响应头,X-RateLimit-Reset为容量完全恢复前的秒数,结果中没有恢复时间时不设置
+func setHeaders(h http.Header, res *limiterhelper.Reservation) {
+	h.Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	if !res.ResetAt.IsZero() {
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(seconds(time.Until(res.ResetAt)), 10))
+	}
+}
+
+//seconds 向上取整的秒数,不小于0
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
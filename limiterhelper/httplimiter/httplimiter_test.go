@@ -0,0 +1,108 @@
+package httplimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/stretchr/testify/assert"
+)
+
+//counterTake 每个主体最多注水limit次的测试用注水函数
+func counterTake(limit int64) (limiterhelper.SubjectTakeFunc, map[string]int64) {
+	counts := map[string]int64{}
+	return func(ctx context.Context, subject string, n int64) (*limiterhelper.Reservation, error) {
+		level := counts[subject] + n
+		if level > limit {
+			return limiterhelper.NewReservation(false, limit, counts[subject], 1500*time.Millisecond, 2*time.Second), nil
+		}
+		counts[subject] = level
+		return limiterhelper.NewReservation(true, limit, level, 0, 2*time.Second), nil
+	}, counts
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func Test_middleware(t *testing.T) {
+	take, counts := counterTake(2)
+	h := New(take, WithSubject(SubjectByIP("X-Forwarded-For"))).Middleware(okHandler)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		//只使用代理追加的最后一个ip,客户端伪造的部分被忽略
+		req.Header.Set("X-Forwarded-For", "10.0.0.2, 10.0.0.1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "2", w.Header().Get("X-RateLimit-Reset"))
+	}
+	assert.Equal(t, int64(2), counts["10.0.0.1"])
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	//没有请求头时使用RemoteAddr
+	req = httptest.NewRequest(http.MethodGet, "/a", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), counts["192.0.2.1"])
+}
+
+func Test_middleware_fail(t *testing.T) {
+	take := func(ctx context.Context, subject string, n int64) (*limiterhelper.Reservation, error) {
+		return nil, errors.New("redis down")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	w := httptest.NewRecorder()
+	New(take).Middleware(okHandler).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	New(take, WithFailClosed()).Middleware(okHandler).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func Test_middleware_subject(t *testing.T) {
+	take, counts := counterTake(1)
+	h := New(take, WithSubject(SubjectByHeader("X-User")), WithoutHeaders(), WithOnLimited(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))).Middleware(okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("X-User", "u1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Header().Get("X-RateLimit-Limit"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, int64(1), counts["u1"])
+	assert.Equal(t, "GET /a", SubjectByMethod()(req))
+}
+
+func Test_middleware_empty_subject(t *testing.T) {
+	take, counts := counterTake(1)
+	//没有请求头时按ip限流,不会绕过限流
+	h := New(take, WithSubject(SubjectByHeader("X-User"))).Middleware(okHandler)
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, int64(1), counts["192.0.2.1"])
+	h = New(take, WithSubject(SubjectByHeader("X-User")), WithRejectEmptySubject()).Middleware(okHandler)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
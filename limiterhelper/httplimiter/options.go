@@ -0,0 +1,118 @@
+package httplimiter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Golang-Tools/optparams"
+)
+
+//SubjectFunc 从请求中获取限流主体的函数
+type SubjectFunc func(r *http.Request) string
+
+type Options struct {
+	Subject   SubjectFunc  //获取限流主体的函数
+	Fallback  SubjectFunc  //主体为空时使用的主体,为nil或结果依然为空时拒绝请求并返回400
+	Cost      int64        //每个请求的注水量
+	FailOpen  bool         //限制器出错时是否放行请求
+	Headers   bool         //是否设置X-RateLimit-*响应头
+	OnLimited http.Handler //被限流时的处理函数,为nil时返回429
+}
+
+var defaultOptions = Options{
+	Subject:  SubjectByIP(),
+	Fallback: SubjectByIP(),
+	Cost:     1,
+	FailOpen: true,
+	Headers:  true,
+}
+
+//WithSubject 设置获取限流主体的函数,默认使用SubjectByIP()
+func WithSubject(fn SubjectFunc) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Subject = fn
+	})
+}
+
+//WithFallbackSubject 设置主体为空(比如请求没有带指定的请求头)时使用的主体,默认使用SubjectByIP()
+//主体为空的请求不会绕过限流,它们会按这里的主体限流
+func WithFallbackSubject(fn SubjectFunc) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Fallback = fn
+	})
+}
+
+//WithRejectEmptySubject 设置主体为空时直接拒绝请求并返回400,不使用备用主体
+func WithRejectEmptySubject() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Fallback = nil
+	})
+}
+
+//WithCost 设置每个请求的注水量,默认1
+func WithCost(cost int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if cost > 0 {
+			o.Cost = cost
+		}
+	})
+}
+
+//WithFailClosed 设置限制器出错(比如redis不可用)时拒绝请求并返回503,默认放行请求
+func WithFailClosed() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.FailOpen = false
+	})
+}
+
+//WithoutHeaders 设置不写入X-RateLimit-*响应头,被限流时依然会设置Retry-After
+func WithoutHeaders() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Headers = false
+	})
+}
+
+//WithOnLimited 设置被限流时的处理函数,响应头已经设置好
+func WithOnLimited(h http.Handler) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.OnLimited = h
+	})
+}
+
+//SubjectByIP 使用客户端ip作为主体
+//@params headers ...string 可信的代理设置的保存客户端ip的请求头,比如X-Forwarded-For,X-Real-IP,按顺序使用第一个有值的请求头中的最后一个ip,都没有时使用RemoteAddr
+//最后一个ip是离服务最近的可信代理追加的,前面的部分可以被客户端伪造,因此只能在服务前只有一层可信代理时使用
+func SubjectByIP(headers ...string) SubjectFunc {
+	return func(r *http.Request) string {
+		for _, header := range headers {
+			v := strings.Join(r.Header.Values(header), ",")
+			ips := strings.Split(v, ",")
+			for i := len(ips) - 1; i >= 0; i-- {
+				if ip := strings.TrimSpace(ips[i]); ip != "" {
+					return ip
+				}
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+//SubjectByHeader 使用请求头的值作为主体,比如用户id,api key
+//@params header string 请求头
+func SubjectByHeader(header string) SubjectFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+//SubjectByMethod 使用请求方法和路径作为主体,每个接口单独限流
+func SubjectByMethod() SubjectFunc {
+	return func(r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}
+}
@@ -4,7 +4,7 @@ package limiterhelper
 
 import "context"
 
-//钩子函数
+//钩子函数,返回的错误会交给设置的HookErrorHandler处理
 //@params res int64   当前水位
 //@params maxsize int64 最大水位
 type Hook func(res, maxsize int64) error
@@ -26,3 +26,42 @@ type LimiterInterface interface {
 	//注册水位满时的钩子,钩子会在执行Flood方法时触发
 	OnFull(fn Hook) error
 }
+
+//RecoveredHookInterface 可以注册水位回落钩子的限制器接口
+//内置了LimiterABC的限制器都实现了该接口
+type RecoveredHookInterface interface {
+	//注册水位回落到警戒水位以下时的钩子,钩子会在执行Flood方法时触发
+	OnRecovered(fn Hook) error
+}
+
+//TakerInterface 可以返回注水详细结果的限制器接口
+type TakerInterface interface {
+	LimiterInterface
+	//注水并返回剩余容量,恢复时间和需要等待的时间等信息
+	Take(context.Context, int64) (*Reservation, error)
+	//阻塞直到注水成功或ctx结束
+	Wait(context.Context, int64) error
+}
+
+//TryTakerInterface 可以在无法注水时不占用容量的限制器接口
+//用于预先获取配额,获取失败时不会影响其他调用方
+type TryTakerInterface interface {
+	//注水并返回详细结果,无法注水时不会占用容量
+	TryTake(context.Context, int64) (*Reservation, error)
+}
+
+//RefunderInterface 可以归还注水量的限制器接口
+//用于归还预先获取但没有用完的配额
+type RefunderInterface interface {
+	//归还注水量,水位不会低于0
+	Refund(context.Context, int64) error
+}
+
+//KeyedFlooderInterface 可以对任意键按指定容量注水的限制器接口
+//实现它的限制器可以用于构造按主体(用户,ip等)限流的限制器,每个主体使用单独的键
+type KeyedFlooderInterface interface {
+	//对指定键注水,返回值true表示注水成功,false表示满了无法注水,无法注水时不会占用容量
+	FloodKey(ctx context.Context, key string, maxsize int64, value int64) (bool, error)
+	//对指定键注水并返回注水的详细结果
+	TakeKey(ctx context.Context, key string, maxsize int64, value int64) (*Reservation, error)
+}
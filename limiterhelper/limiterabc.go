@@ -1,17 +1,88 @@
 package limiterhelper
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Golang-Tools/optparams"
 )
 
+//HookEvent 钩子对应的事件
+type HookEvent uint16
+
+const (
+	//HookEvent__Warning 水位到达警戒水位
+	HookEvent__Warning HookEvent = iota
+	//HookEvent__Full 水位已满或注水被拒绝
+	HookEvent__Full
+	//HookEvent__Recovered 水位从警戒或已满的状态回落到警戒水位以下,可以用于清除告警
+	HookEvent__Recovered
+)
+
+func (e HookEvent) String() string {
+	switch e {
+	case HookEvent__Warning:
+		return "warning"
+	case HookEvent__Full:
+		return "full"
+	case HookEvent__Recovered:
+		return "recovered"
+	default:
+		return "unknown"
+	}
+}
+
+//HookErrorHandler 钩子返回错误或panic时的处理函数
+//@params event HookEvent 钩子对应的事件
+//@params res int64 触发钩子时的水位
+//@params maxsize int64 最大水位
+//@params err error 钩子返回的错误
+type HookErrorHandler func(event HookEvent, res, maxsize int64, err error)
+
+//hookDropReportInterval 报告队列已满丢弃钩子的最小间隔
+const hookDropReportInterval = time.Second
+
+//水位状态,用于判断是否需要触发HookEvent__Recovered
+const (
+	stateNormal int32 = iota
+	stateWarning
+	stateFull
+)
+
+//hookEntry 注册的钩子
+type hookEntry struct {
+	id uint64
+	fn Hook
+}
+
+//hookCall 等待异步执行的钩子
+type hookCall struct {
+	event   HookEvent
+	fn      Hook
+	res     int64
+	maxsize int64
+}
+
 //LimiterABC 分布式限制器公用组件
+//每个事件可以注册多个钩子,钩子按注册顺序执行
 type LimiterABC struct {
+	//Deprecated: 使用OnWarning或Subscribe注册钩子,直接设置的WarningHook依然会在注册的钩子之后触发
 	WarningHook Hook
-	FullHook    Hook
-	Hookslock   sync.RWMutex
-	Opt         Options
+	//Deprecated: 使用OnFull或Subscribe注册钩子,直接设置的FullHook依然会在注册的钩子之后触发
+	FullHook Hook
+	//Hookslock 保护钩子的注册,直接设置WarningHook或FullHook时需要持有它
+	Hookslock sync.RWMutex
+	Opt       Options
+	hooks     map[HookEvent][]hookEntry
+	hookseq   uint64
+	state     int32
+	queue     chan hookCall
+	stop      chan struct{}
+	stopOnce  sync.Once
+	dropped   int64 //上次报告后被丢弃的钩子数
+	reportAt  int64 //上次报告丢弃钩子的时间(unix ns)
 }
 
 func New(opts ...optparams.Option[Options]) (*LimiterABC, error) {
@@ -21,54 +92,158 @@ func New(opts ...optparams.Option[Options]) (*LimiterABC, error) {
 	if l.Opt.MaxSize <= l.Opt.WarningSize {
 		return nil, ErrLimiterMaxSizeMustLargerThanWaringSize
 	}
+	l.hooks = map[HookEvent][]hookEntry{}
+	if l.Opt.HookQueueSize > 0 {
+		l.queue = make(chan hookCall, l.Opt.HookQueueSize)
+		l.stop = make(chan struct{})
+		go l.dispatch()
+	}
 	return l, nil
 }
 
-//OnWarning 注册在到警戒水位时的钩子
+//Subscribe 注册事件的钩子
+//@params event HookEvent 事件
 //@params fn Hook 钩子函数
-func (c *LimiterABC) OnWarning(fn Hook) error {
+//@returns func() 取消注册的函数,可以多次调用
+func (c *LimiterABC) Subscribe(event HookEvent, fn Hook) func() {
 	c.Hookslock.Lock()
 	defer c.Hookslock.Unlock()
-	if c.WarningHook != nil {
-		return ErrAlreadyHasHook
+	c.hookseq++
+	id := c.hookseq
+	c.hooks[event] = append(c.hooks[event], hookEntry{id: id, fn: fn})
+	return func() {
+		c.Hookslock.Lock()
+		defer c.Hookslock.Unlock()
+		entries := c.hooks[event]
+		for i, entry := range entries {
+			if entry.id == id {
+				c.hooks[event] = append(entries[:i:i], entries[i+1:]...)
+				return
+			}
+		}
 	}
-	c.WarningHook = fn
+}
 
+//OnWarning 注册在到警戒水位时的钩子,需要取消注册时使用Subscribe
+//@params fn Hook 钩子函数
+func (c *LimiterABC) OnWarning(fn Hook) error {
+	c.Subscribe(HookEvent__Warning, fn)
 	return nil
 }
 
-//OnFull 注册在到水位漫时的钩子
+//OnFull 注册在到水位漫时的钩子,需要取消注册时使用Subscribe
 //@params fn Hook 钩子函数
 func (c *LimiterABC) OnFull(fn Hook) error {
-	c.Hookslock.Lock()
-	defer c.Hookslock.Unlock()
-	if c.FullHook != nil {
-		return ErrAlreadyHasHook
-	}
-	c.FullHook = fn
+	c.Subscribe(HookEvent__Full, fn)
 	return nil
 }
 
-//CheckWaterline 检查水位是否已满
+//OnRecovered 注册水位回落到警戒水位以下时的钩子,需要取消注册时使用Subscribe
+//@params fn Hook 钩子函数
+func (c *LimiterABC) OnRecovered(fn Hook) error {
+	c.Subscribe(HookEvent__Recovered, fn)
+	return nil
+}
+
+//fire 触发事件,同步执行或放入异步执行的队列
+func (c *LimiterABC) fire(event HookEvent, size int64) {
+	c.Hookslock.RLock()
+	fns := make([]Hook, 0, len(c.hooks[event])+1)
+	for _, entry := range c.hooks[event] {
+		fns = append(fns, entry.fn)
+	}
+	switch {
+	case event == HookEvent__Warning && c.WarningHook != nil:
+		fns = append(fns, c.WarningHook)
+	case event == HookEvent__Full && c.FullHook != nil:
+		fns = append(fns, c.FullHook)
+	}
+	c.Hookslock.RUnlock()
+	for _, fn := range fns {
+		call := hookCall{event: event, fn: fn, res: size, maxsize: c.Opt.MaxSize}
+		if c.queue == nil {
+			c.call(call)
+			continue
+		}
+		select {
+		case c.queue <- call:
+		default:
+			c.dropCall(call)
+		}
+	}
+}
+
+//dropCall 记录队列已满被丢弃的钩子,距离上次报告超过hookDropReportInterval时调用错误处理函数报告这段时间内丢弃的钩子数
+//水位持续高于警戒水位时每次注水都会触发钩子,限制报告的频率避免错误处理函数被大量调用
+func (c *LimiterABC) dropCall(call hookCall) {
+	atomic.AddInt64(&c.dropped, 1)
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&c.reportAt)
+	if now-last < int64(hookDropReportInterval) || !atomic.CompareAndSwapInt64(&c.reportAt, last, now) {
+		return
+	}
+	dropped := atomic.SwapInt64(&c.dropped, 0)
+	c.Opt.HookErrorHandler(call.event, call.res, call.maxsize, fmt.Errorf("%w: %d hooks dropped", ErrHookQueueFull, dropped))
+}
+
+//call 执行钩子,钩子返回错误或panic时调用错误处理函数
+func (c *LimiterABC) call(call hookCall) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Opt.HookErrorHandler(call.event, call.res, call.maxsize, fmt.Errorf("hook panic: %v", r))
+		}
+	}()
+	err := call.fn(call.res, call.maxsize)
+	if err != nil {
+		c.Opt.HookErrorHandler(call.event, call.res, call.maxsize, err)
+	}
+}
+
+//dispatch 异步执行队列中的钩子
+func (c *LimiterABC) dispatch() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case call := <-c.queue:
+			c.call(call)
+		}
+	}
+}
+
+//StopHooks 停止异步执行钩子的goroutine,队列中尚未执行的钩子会被丢弃,同步执行钩子时无效果
+func (c *LimiterABC) StopHooks() {
+	if c.stop == nil {
+		return
+	}
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+//CheckWaterline 检查水位是否已满并触发对应的钩子
+//水位从警戒或已满的状态回落到警戒水位以下时触发HookEvent__Recovered,状态只记录在当前进程中
 //@params size int64 当前水位
+//@params blocked bool 注水是否被拒绝
 //@returns bool 是否水位已满
 func (l *LimiterABC) CheckWaterline(size int64, blocked bool) bool {
-	if blocked {
-		if l.FullHook != nil {
-			l.FullHook(size, l.Opt.MaxSize)
-		}
-		return true
+	state := stateNormal
+	switch {
+	case blocked || size >= l.Opt.MaxSize:
+		state = stateFull
+	case size >= l.Opt.WarningSize:
+		state = stateWarning
 	}
-	if size >= l.Opt.MaxSize {
-		if l.FullHook != nil {
-			l.FullHook(size, l.Opt.MaxSize)
-		}
+	prev := atomic.SwapInt32(&l.state, state)
+	switch state {
+	case stateFull:
+		l.fire(HookEvent__Full, size)
 		return true
-	} else {
-		if size >= l.Opt.WarningSize {
-			if l.WarningHook != nil {
-				l.WarningHook(size, l.Opt.MaxSize)
-			}
+	case stateWarning:
+		l.fire(HookEvent__Warning, size)
+	default:
+		if prev != stateNormal {
+			l.fire(HookEvent__Recovered, size)
 		}
 	}
 	return false
@@ -0,0 +1,165 @@
+package limiterhelper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//recorder 记录钩子的触发
+type recorder struct {
+	lock   sync.Mutex
+	events []string
+}
+
+func (r *recorder) hook(name string) Hook {
+	return func(res, maxsize int64) error {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.events = append(r.events, name)
+		return nil
+	}
+}
+
+func (r *recorder) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func Test_hooks_subscribe(t *testing.T) {
+	l, err := New(WithMaxSize(10), WithWarningSize(5))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter abc new get error")
+	}
+	r := &recorder{}
+	l.OnWarning(r.hook("warning1"))
+	unsubscribe := l.Subscribe(HookEvent__Warning, r.hook("warning2"))
+	l.OnFull(r.hook("full"))
+	l.OnRecovered(r.hook("recovered"))
+	assert.Equal(t, false, l.CheckWaterline(3, false))
+	assert.Equal(t, false, l.CheckWaterline(6, false))
+	assert.Equal(t, true, l.CheckWaterline(10, false))
+	assert.Equal(t, true, l.CheckWaterline(8, true))
+	assert.Equal(t, false, l.CheckWaterline(2, false))
+	assert.Equal(t, false, l.CheckWaterline(1, false))
+	assert.Equal(t, []string{"warning1", "warning2", "full", "full", "recovered"}, r.get())
+	unsubscribe()
+	unsubscribe()
+	l.CheckWaterline(6, false)
+	assert.Equal(t, []string{"warning1", "warning2", "full", "full", "recovered", "warning1"}, r.get())
+}
+
+func Test_hooks_deprecated_fields(t *testing.T) {
+	l, err := New(WithMaxSize(10), WithWarningSize(5))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter abc new get error")
+	}
+	r := &recorder{}
+	l.OnWarning(r.hook("warning"))
+	//直接设置的旧字段依然会触发
+	l.Hookslock.Lock()
+	l.WarningHook = r.hook("legacy_warning")
+	l.FullHook = r.hook("legacy_full")
+	l.Hookslock.Unlock()
+	l.CheckWaterline(6, false)
+	l.CheckWaterline(10, false)
+	assert.Equal(t, []string{"warning", "legacy_warning", "legacy_full"}, r.get())
+}
+
+func Test_hooks_error(t *testing.T) {
+	var lock sync.Mutex
+	errs := []string{}
+	l, err := New(WithMaxSize(10), WithWarningSize(5), WithHookErrorHandler(func(event HookEvent, res, maxsize int64, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		errs = append(errs, event.String()+":"+err.Error())
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter abc new get error")
+	}
+	l.OnWarning(func(res, maxsize int64) error {
+		return errors.New("alert failed")
+	})
+	l.OnFull(func(res, maxsize int64) error {
+		panic("boom")
+	})
+	l.CheckWaterline(5, false)
+	l.CheckWaterline(10, false)
+	assert.Equal(t, []string{"warning:alert failed", "full:hook panic: boom"}, errs)
+}
+
+func Test_hooks_async(t *testing.T) {
+	var lock sync.Mutex
+	dropped := 0
+	l, err := New(WithMaxSize(10), WithWarningSize(5), WithAsyncHooks(1), WithHookErrorHandler(func(event HookEvent, res, maxsize int64, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if errors.Is(err, ErrHookQueueFull) {
+			dropped++
+		}
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter abc new get error")
+	}
+	defer l.StopHooks()
+	r := &recorder{}
+	block := make(chan struct{})
+	l.OnFull(func(res, maxsize int64) error {
+		<-block
+		return r.hook("full")(res, maxsize)
+	})
+	start := time.Now()
+	//第一个钩子阻塞在执行中,第二个在队列中,第三个被丢弃
+	for i := 0; i < 3; i++ {
+		l.CheckWaterline(10, false)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+	close(block)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, []string{"full", "full"}, r.get())
+	lock.Lock()
+	assert.Equal(t, 1, dropped)
+	lock.Unlock()
+}
+
+func Test_hooks_async_drop_report(t *testing.T) {
+	var lock sync.Mutex
+	reports := []error{}
+	l, err := New(WithMaxSize(10), WithWarningSize(5), WithAsyncHooks(1), WithHookErrorHandler(func(event HookEvent, res, maxsize int64, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		reports = append(reports, err)
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter abc new get error")
+	}
+	defer l.StopHooks()
+	block := make(chan struct{})
+	defer close(block)
+	l.OnFull(func(res, maxsize int64) error {
+		<-block
+		return nil
+	})
+	l.CheckWaterline(10, false)
+	time.Sleep(10 * time.Millisecond)
+	//水位持续已满时丢弃的钩子每秒最多报告一次
+	for i := 0; i < 10; i++ {
+		l.CheckWaterline(10, false)
+	}
+	lock.Lock()
+	assert.Equal(t, 1, len(reports))
+	lock.Unlock()
+	time.Sleep(hookDropReportInterval)
+	l.CheckWaterline(10, false)
+	lock.Lock()
+	defer lock.Unlock()
+	if assert.Equal(t, 2, len(reports)) {
+		assert.True(t, errors.Is(reports[1], ErrHookQueueFull))
+		assert.Equal(t, "hook queue full: 9 hooks dropped", reports[1].Error())
+	}
+}
@@ -1,19 +1,25 @@
 package limiterhelper
 
 import (
+	log "github.com/Golang-Tools/loggerhelper/v2"
 	"github.com/Golang-Tools/optparams"
 )
 
 //Options broker的配置
 type Options struct {
-	MaxSize     int64
-	WarningSize int64
+	MaxSize          int64
+	WarningSize      int64
+	HookQueueSize    int              //异步执行钩子的队列长度,为0时在注水时同步执行钩子
+	HookErrorHandler HookErrorHandler //钩子返回错误或panic时的处理函数
 }
 
 //Defaultopt 默认的可选配置
 var Defaultopt = Options{
 	MaxSize:     100,
 	WarningSize: 80,
+	HookErrorHandler: func(event HookEvent, res, maxsize int64, err error) {
+		log.Warn("limiter hook get error", log.Dict{"event": event.String(), "res": res, "maxsize": maxsize, "err": err.Error()})
+	},
 }
 
 //WithMaxSize 设置最大水位,必须大于0
@@ -33,3 +39,23 @@ func WithWarningSize(warningSize int64) optparams.Option[Options] {
 		}
 	})
 }
+
+//WithAsyncHooks 设置异步执行钩子,钩子在后台的goroutine中按触发顺序执行,注水不会被钩子阻塞
+//队列满时新触发的钩子会被丢弃,并以包装了ErrHookQueueFull的错误调用钩子的错误处理函数,每秒最多调用一次
+//@params queueSize int 队列长度,必须大于0
+func WithAsyncHooks(queueSize int) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if queueSize > 0 {
+			o.HookQueueSize = queueSize
+		}
+	})
+}
+
+//WithHookErrorHandler 设置钩子返回错误或panic时的处理函数,默认打印警告日志
+func WithHookErrorHandler(fn HookErrorHandler) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if fn != nil {
+			o.HookErrorHandler = fn
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package limiterhelper
+
+import (
+	"time"
+)
+
+//Reservation 注水的详细结果,可以用于设置Retry-After,X-RateLimit-Remaining等响应头
+type Reservation struct {
+	Allowed    bool          //是否注水成功
+	Limit      int64         //容量
+	Remaining  int64         //剩余容量
+	ResetAt    time.Time     //容量完全恢复的时间
+	RetryAfter time.Duration //注水失败时需要等待多久才能再次尝试,注水成功时为0,为-1表示注水量超过容量永远无法成功
+}
+
+//NewReservation 根据水位构造注水结果
+//@params allowed bool 是否注水成功
+//@params limit int64 容量
+//@params level int64 注水后的水位
+//@params retryAfter time.Duration 需要等待的时间,注水成功时会被忽略
+//@params resetAfter time.Duration 距离容量完全恢复的时间
+func NewReservation(allowed bool, limit, level int64, retryAfter, resetAfter time.Duration) *Reservation {
+	r := &Reservation{
+		Allowed: allowed,
+		Limit:   limit,
+		ResetAt: time.Now().Add(resetAfter),
+	}
+	if level < limit {
+		r.Remaining = limit - level
+	}
+	if !allowed {
+		r.RetryAfter = retryAfter
+	}
+	return r
+}
+
+//WaterLevel 注水后的水位
+func (r *Reservation) WaterLevel() int64 {
+	return r.Limit - r.Remaining
+}
@@ -0,0 +1,27 @@
+package limiterhelper
+
+import (
+	"context"
+)
+
+//SubjectTakeFunc 按主体注水并返回详细结果的函数,http中间件和grpc拦截器使用它限流
+//keyedlimiter.Limiter的Take方法可以直接作为SubjectTakeFunc使用
+type SubjectTakeFunc func(ctx context.Context, subject string, n int64) (*Reservation, error)
+
+//SubjectTake 将限制器包装为SubjectTakeFunc,所有主体共用限制器的容量
+//限制器实现了TakerInterface时使用Take获取详细结果,否则使用Flood的结果构造只有是否成功和容量的结果
+//@params l LimiterInterface 限制器
+func SubjectTake(l LimiterInterface) SubjectTakeFunc {
+	if taker, ok := l.(TakerInterface); ok {
+		return func(ctx context.Context, subject string, n int64) (*Reservation, error) {
+			return taker.Take(ctx, n)
+		}
+	}
+	return func(ctx context.Context, subject string, n int64) (*Reservation, error) {
+		ok, err := l.Flood(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		return &Reservation{Allowed: ok, Limit: l.Capacity()}, nil
+	}
+}
@@ -0,0 +1,50 @@
+package limiterhelper
+
+import (
+	"context"
+	"time"
+)
+
+//MinWaitInterval 注水结果中需要等待的时间为0时(例如redis-cell返回的时间精度为秒)两次尝试间的最短等待时间
+var MinWaitInterval = 10 * time.Millisecond
+
+//TakeFunc 注水并返回详细结果的函数
+type TakeFunc func(ctx context.Context, n int64) (*Reservation, error)
+
+//Wait 阻塞直到注水成功
+//每次注水失败后按结果中的RetryAfter等待后重试,ctx结束时返回ctx.Err()
+//注水量超过容量永远无法成功时返回ErrWaitNeverAllowed,ctx的截止时间早于需要等待的时间时直接返回ErrWaitExceedsDeadline
+//@params take TakeFunc 注水函数
+//@params n int64 注水量
+func Wait(ctx context.Context, take TakeFunc, n int64) error {
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	for {
+		res, err := take(ctx, n)
+		if err != nil {
+			return err
+		}
+		if res.Allowed {
+			return nil
+		}
+		if res.RetryAfter < 0 {
+			return ErrWaitNeverAllowed
+		}
+		wait := res.RetryAfter
+		if wait < MinWaitInterval {
+			wait = MinWaitInterval
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return ErrWaitExceedsDeadline
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
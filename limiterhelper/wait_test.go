@@ -0,0 +1,51 @@
+package limiterhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_wait(t *testing.T) {
+	calls := 0
+	take := func(ctx context.Context, n int64) (*Reservation, error) {
+		calls++
+		if calls < 3 {
+			return NewReservation(false, 10, 10, 50*time.Millisecond, time.Second), nil
+		}
+		return NewReservation(true, 10, 10, 0, time.Second), nil
+	}
+	start := time.Now()
+	err := Wait(context.Background(), take, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func Test_wait_never_allowed(t *testing.T) {
+	take := func(ctx context.Context, n int64) (*Reservation, error) {
+		return NewReservation(false, 10, 0, -1, 0), nil
+	}
+	err := Wait(context.Background(), take, 11)
+	assert.Equal(t, ErrWaitNeverAllowed, err)
+}
+
+func Test_wait_context(t *testing.T) {
+	take := func(ctx context.Context, n int64) (*Reservation, error) {
+		return NewReservation(false, 10, 10, time.Second, time.Second), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := Wait(ctx, take, 1)
+	assert.Equal(t, ErrWaitExceedsDeadline, err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err = Wait(ctx, take, 1)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,251 @@
+package lock
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//fairLockScript 公平模式下获取锁的脚本
+//KEYS[1]为锁,KEYS[2]为等待队列,KEYS[3]为记录等待者过期时间的hash,KEYS[4]为fencing token计数器
+//ARGV[1]为客户端id,ARGV[2]为锁的过期时间(ms),ARGV[3]为等待者id,ARGV[4]为等待者的过期时间(ms),ARGV[5]为是否可重入,ARGV[6]为是否生成fencing token,ARGV[7]为未获得锁时是否排队
+//返回{持有次数,fencing token}
+// 持有次数为0表示未获得锁,ARGV[7]为1时已在队列中排队
+// 持有次数大于0表示获得锁,非可重入锁时为1
+var fairLockScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local ttl = tonumber(ARGV[2])
+local waiterttl = tonumber(ARGV[4])
+if ARGV[5] == "1" and redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+	local count = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+	if ttl > 0 then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	redis.call("LREM", KEYS[2], 0, ARGV[3])
+	redis.call("HDEL", KEYS[3], ARGV[3])
+	return {count, tonumber(redis.call("HGET", KEYS[1], "::fencing_token") or "0")}
+end
+while true do
+	local head = redis.call("LINDEX", KEYS[2], 0)
+	if not head or head == ARGV[3] then
+		break
+	end
+	local exp = redis.call("HGET", KEYS[3], head)
+	if exp and tonumber(exp) >= now then
+		break
+	end
+	redis.call("LPOP", KEYS[2])
+	redis.call("HDEL", KEYS[3], head)
+end
+local head = redis.call("LINDEX", KEYS[2], 0)
+if redis.call("EXISTS", KEYS[1]) == 0 and (not head or head == ARGV[3]) then
+	local count = 1
+	local token = 0
+	if ARGV[6] == "1" then
+		token = redis.call("INCR", KEYS[4])
+	end
+	if ARGV[5] == "1" then
+		count = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+		if ARGV[6] == "1" then
+			redis.call("HSET", KEYS[1], "::fencing_token", token)
+		end
+	else
+		redis.call("SET", KEYS[1], ARGV[1])
+	end
+	if ttl > 0 then
+		redis.call("PEXPIRE", KEYS[1], ttl)
+	end
+	if head then
+		redis.call("LPOP", KEYS[2])
+	end
+	redis.call("HDEL", KEYS[3], ARGV[3])
+	return {count, token}
+end
+if ARGV[7] ~= "1" then
+	return {0, 0}
+end
+if redis.call("HEXISTS", KEYS[3], ARGV[3]) == 0 then
+	redis.call("RPUSH", KEYS[2], ARGV[3])
+end
+redis.call("HSET", KEYS[3], ARGV[3], now + waiterttl)
+redis.call("PEXPIRE", KEYS[2], waiterttl * 2)
+redis.call("PEXPIRE", KEYS[3], waiterttl * 2)
+return {0, 0}`)
+
+//QueueKey 公平模式下等待队列使用的键,与锁落在同一个集群slot
+func (l *Lock) QueueKey() string {
+	return l.SubKey("::queue")
+}
+
+//waitersKey 公平模式下记录等待者过期时间使用的键,与锁落在同一个集群slot
+func (l *Lock) waitersKey() string {
+	return l.SubKey("::waiters")
+}
+
+//Channel 公平模式下锁释放时发布通知的频道
+func (l *Lock) Channel() string {
+	return l.Key() + "::release"
+}
+
+//Backoff 计算第attempt次重试前需要等待的时间,使用带随机抖动的指数退避
+//@params base time.Duration 初始间隔
+//@params max time.Duration 最大间隔
+//@params attempt int 已重试次数,从0开始
+//@returns time.Duration 等待时间,范围在[d/2,d)之间,d为min(base*2^attempt,max)
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	d := max
+	if attempt < 32 {
+		if b := base << attempt; b > 0 && b < d {
+			d = b
+		}
+	}
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + rand.Int63n(half))
+}
+
+//RetryWithBackoff 使用带随机抖动的指数退避重复执行fn,直到fn返回的错误不是retryOn或ctx结束
+//@params base time.Duration 初始间隔
+//@params max time.Duration 最大间隔
+//@params retryOn error fn返回该错误时等待后重试
+func RetryWithBackoff(ctx context.Context, base, max time.Duration, retryOn error, fn func(context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err != retryOn {
+			return err
+		}
+		timer := time.NewTimer(Backoff(base, max, attempt))
+		select {
+		case <-ctx.Done():
+			{
+				timer.Stop()
+				return ctx.Err()
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+//TryFor 在timeout时间内执行acquire,超时返回timeoutErr,ctx本身结束时返回ctx的错误
+//@params timeout time.Duration 最长等待时间
+//@params timeoutErr error 超时时返回的错误
+func TryFor(ctx context.Context, timeout time.Duration, timeoutErr error, acquire func(context.Context) error) error {
+	ctx1, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := acquire(ctx1)
+	if err != nil && ctx.Err() == nil && ctx1.Err() == context.DeadlineExceeded {
+		return timeoutErr
+	}
+	return err
+}
+
+//Acquire 阻塞直到获得锁或ctx结束
+//默认使用带随机抖动的指数退避重试,公平模式下按排队顺序获得锁
+func (l *Lock) Acquire(ctx context.Context) error {
+	if l.opt.Fair {
+		return l.acquireFair(ctx)
+	}
+	return RetryWithBackoff(ctx, l.opt.CheckPeriod, l.opt.MaxBackoff, ErrAlreadyLocked, l.Lock)
+}
+
+//TryLockFor 在指定时间内尝试获得锁,超时仍未获得时返回ErrAlreadyLocked
+//@params ctx context.Context 上下文信息,ctx结束时返回ctx的错误
+//@params timeout time.Duration 最长等待时间
+func (l *Lock) TryLockFor(ctx context.Context, timeout time.Duration) error {
+	return TryFor(ctx, timeout, ErrAlreadyLocked, l.Acquire)
+}
+
+//acquireFair 公平模式获取锁
+//等待者在队列中排队,每次被锁释放的通知唤醒或最多等待MaxBackoff后重试,等待者超过3倍MaxBackoff未重试会被移出队列
+func (l *Lock) acquireFair(ctx context.Context) error {
+	waiter := l.newWaiter()
+	pubsub := l.Client().Subscribe(ctx, l.Channel())
+	defer pubsub.Close()
+	notify := pubsub.Channel()
+	for {
+		count, err := l.fairLock(ctx, waiter, true)
+		if err != nil {
+			l.leaveQueue(waiter)
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		timer := time.NewTimer(l.opt.MaxBackoff)
+		select {
+		case <-ctx.Done():
+			{
+				timer.Stop()
+				l.leaveQueue(waiter)
+				return ctx.Err()
+			}
+		case <-notify:
+			{
+				timer.Stop()
+			}
+		case <-timer.C:
+		}
+	}
+}
+
+//newWaiter 生成公平模式下的等待者id
+func (l *Lock) newWaiter() string {
+	return l.ClientID() + "::" + hex.EncodeToString(uuid.NewV4().Bytes())
+}
+
+//fairLock 公平模式下尝试获取一次锁,获得锁时记录fencing token并在首次获得时启动看门狗
+//@params waiter string 等待者id
+//@params enqueue bool 未获得锁时是否进入队列排队
+//@returns int64 持有次数,为0表示未获得锁
+func (l *Lock) fairLock(ctx context.Context, waiter string, enqueue bool) (int64, error) {
+	keys := []string{l.Key(), l.QueueKey(), l.waitersKey(), l.FencingKey()}
+	waiterttl := 3 * l.opt.MaxBackoff.Milliseconds()
+	res, err := fairLockScript.Run(ctx, l.Client(), keys, l.ClientID(), l.MaxTTL().Milliseconds(), waiter, waiterttl, boolArg(l.opt.Reentrant), boolArg(l.opt.FencingToken), boolArg(enqueue)).Slice()
+	if err != nil {
+		return 0, err
+	}
+	count, token, err := parseAcquireResult(res)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		l.setToken(token)
+		if count == 1 {
+			l.startWatchDog()
+		}
+	}
+	return count, nil
+}
+
+//leaveQueue 等待者放弃等待时将自己移出队列
+func (l *Lock) leaveQueue(waiter string) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.opt.MaxBackoff)
+	defer cancel()
+	pipe := l.Client().TxPipeline()
+	pipe.LRem(ctx, l.QueueKey(), 0, waiter)
+	pipe.HDel(ctx, l.waitersKey(), waiter)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		l.Logger().Warn("leave lock queue get error", log.Dict{"err": err.Error()})
+	}
+}
+
+//notifyRelease 公平模式下锁释放后通知等待者
+func (l *Lock) notifyRelease(ctx context.Context) {
+	if !l.opt.Fair {
+		return
+	}
+	_, err := l.Client().Publish(ctx, l.Channel(), l.ClientID()).Result()
+	if err != nil {
+		l.Logger().Warn("notify lock release get error", log.Dict{"err": err.Error()})
+	}
+}
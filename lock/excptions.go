@@ -15,3 +15,27 @@ var ErrNoRightToUnLock = errors.New("no right to unlock")
 
 //ErrCheckPeriodLessThan100Microsecond checkperiod小于100微秒
 var ErrCheckPeriodLessThan100Microsecond = errors.New("checkperiod less than 100 microsecond")
+
+//ErrNotLockOwner 锁不存在或不属于当前客户端
+var ErrNotLockOwner = errors.New("not lock owner")
+
+//ErrWatchDogNeedMaxTTL 看门狗模式需要设置MaxTTL
+var ErrWatchDogNeedMaxTTL = errors.New("watchdog need MaxTTL")
+
+//ErrWatchDogIntervalTooLong 看门狗的续期间隔必须小于MaxTTL
+var ErrWatchDogIntervalTooLong = errors.New("watchdog interval must less than MaxTTL")
+
+//ErrMaxBackoffLessThanCheckPeriod 最大退避间隔小于checkperiod
+var ErrMaxBackoffLessThanCheckPeriod = errors.New("max backoff less than checkperiod")
+
+//ErrPermitsMustLargerThanZero 信号量的许可数必须大于0
+var ErrPermitsMustLargerThanZero = errors.New("permits must larger than 0")
+
+//ErrSemaphoreNeedMaxTTL 信号量需要设置MaxTTL作为租约时长
+var ErrSemaphoreNeedMaxTTL = errors.New("semaphore need MaxTTL")
+
+//ErrNoPermits 信号量已没有可用的许可
+var ErrNoPermits = errors.New("no permits available")
+
+//ErrLeaseNotExists 租约不存在或已过期
+var ErrLeaseNotExists = errors.New("lease not exists")
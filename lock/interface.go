@@ -1,10 +1,25 @@
 package lock
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 //LockInterface 锁对象的接口
 type LockInterface interface {
 	Lock(context.Context) error
 	Unlock(context.Context) error
 	Wait(context.Context) error
+	//Acquire 阻塞直到获得锁或ctx结束
+	Acquire(context.Context) error
+	//TryLockFor 在指定时间内尝试获得锁,超时仍未获得时返回ErrAlreadyLocked
+	TryLockFor(context.Context, time.Duration) error
+}
+
+//FencingTokenInterface 可以提供fencing token的锁对象的接口
+type FencingTokenInterface interface {
+	//Token 当前持有锁对应的fencing token,未持有锁或未启用时为0
+	Token() int64
+	//FencingEnabled 是否启用了fencing token
+	FencingEnabled() bool
 }
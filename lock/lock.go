@@ -1,12 +1,18 @@
 //Package lock 分布式锁实现
 //分布式锁需要有客户端信息,只可以获得锁的客户端自己解锁或者等待锁自己过期
-//当未能获得锁需要等待锁释放时可以通过wait接口实现
+//当未能获得锁需要等待锁释放时可以通过wait接口实现,需要等待并获得锁时可以通过Acquire和TryLockFor接口实现
+//设置可重入后同一客户端可以多次获得锁,此时锁使用hash结构记录客户端的持有次数
+//设置看门狗后持有锁期间会定时为锁续期,避免执行时间过长导致锁过期被其他客户端获得
 package lock
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	log "github.com/Golang-Tools/loggerhelper/v2"
 	"github.com/Golang-Tools/optparams"
 	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
 	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
@@ -16,11 +22,109 @@ import (
 //MiniCheckPeriod 等待的轮询间隔最低100微秒
 const MiniCheckPeriod = 100 * time.Microsecond
 
+//UnlockScript 释放锁的脚本,只有锁的持有者可以释放锁,KEYS[1]为锁,ARGV[1]为客户端id
+// 1表示删成功
+// 2表示key不存在
+// 3表示key不匹配
+var UnlockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then 
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	else
+		return 3
+	end
+else
+	return 2
+end`)
+
+//ExtendScript 持有者为锁续期的脚本,KEYS[1]为锁,ARGV[1]为客户端id,ARGV[2]为过期时间(ms)
+// 1表示续期成功
+// 0表示key不存在或key不匹配
+var ExtendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end`)
+
+//fencingLockScript 获取锁并生成fencing token的脚本
+//KEYS[1]为锁,KEYS[2]为fencing token计数器,ARGV[1]为客户端id,ARGV[2]为过期时间(ms)
+// 返回0表示锁被其他客户端持有
+// 返回大于0的值表示获得锁,值为本次获得锁的fencing token
+var fencingLockScript = redis.NewScript(`
+local ok
+if tonumber(ARGV[2]) > 0 then
+	ok = redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2])
+else
+	ok = redis.call("SET", KEYS[1], ARGV[1], "NX")
+end
+if ok then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+//reentrantLockScript 获取可重入锁的脚本,锁使用hash结构,field为客户端id,value为持有次数
+//KEYS[1]为锁,KEYS[2]为fencing token计数器
+//ARGV[1]为客户端id,ARGV[2]为过期时间(ms),ARGV[3]为是否生成fencing token
+//返回{持有次数,fencing token}
+// 持有次数为0表示锁被其他客户端持有
+// 首次获得锁时生成fencing token并记录在hash中,重入时返回记录的fencing token,未启用时为0
+var reentrantLockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+	local count = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+	local token = 0
+	if ARGV[3] == "1" then
+		if count == 1 then
+			token = redis.call("INCR", KEYS[2])
+			redis.call("HSET", KEYS[1], "::fencing_token", token)
+		else
+			token = tonumber(redis.call("HGET", KEYS[1], "::fencing_token") or "0")
+		end
+	end
+	if tonumber(ARGV[2]) > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	return {count, token}
+else
+	return {0, 0}
+end`)
+
+//reentrantUnlockScript 释放可重入锁的脚本,返回值与UnlockScript一致
+// 1表示持有次数归零,删成功
+// 2表示key不存在
+// 3表示key不匹配
+// 4表示持有次数减1,依然持有锁
+var reentrantUnlockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then 
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+		if redis.call("HINCRBY", KEYS[1], ARGV[1], -1) <= 0 then
+			return redis.call("DEL", KEYS[1])
+		else
+			return 4
+		end
+	else
+		return 3
+	end
+else
+	return 2
+end`)
+
+//reentrantExtendScript 持有者为可重入锁续期的脚本,返回值与ExtendScript一致
+var reentrantExtendScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end`)
+
 // Lock 分布式锁结构
 type Lock struct {
 	opt Options
 	*middlewarehelper.MiddleWareAbc
 	*clientIdhelper.ClientIDAbc
+	watchdogLock   sync.Mutex
+	watchdogCancel context.CancelFunc
+	token          int64
 }
 
 //New 新建一个锁对象
@@ -31,10 +135,24 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Lock, e
 	if l.opt.CheckPeriod < MiniCheckPeriod {
 		return nil, ErrCheckPeriodLessThan100Microsecond
 	}
+	if l.opt.MaxBackoff < l.opt.CheckPeriod {
+		return nil, ErrMaxBackoffLessThanCheckPeriod
+	}
 	meta, err := middlewarehelper.New(cli, "lock", l.opt.MiddlewareOpts...)
 	if err != nil {
 		return nil, err
 	}
+	if l.opt.WatchDog {
+		if meta.MaxTTL() <= 0 {
+			return nil, ErrWatchDogNeedMaxTTL
+		}
+		if l.opt.WatchDogInterval <= 0 {
+			l.opt.WatchDogInterval = meta.MaxTTL() / 3
+		}
+		if l.opt.WatchDogInterval >= meta.MaxTTL() {
+			return nil, ErrWatchDogIntervalTooLong
+		}
+	}
 	cid, err := clientIdhelper.New(l.opt.ClientIDOpts...)
 	l.MiddleWareAbc = meta
 	l.ClientIDAbc = cid
@@ -44,52 +162,223 @@ func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Lock, e
 //写操作
 
 //Lock 设置锁
+//可重入锁被当前客户端持有时会增加持有次数
+//启用看门狗时首次获得锁会启动续期任务
+//启用fencing token时获得锁会生成新的fencing token,可以通过Token()获取
+//公平模式下只有队列中没有其他有效的等待者时才能获得锁,获取失败时不会进入队列
 func (l *Lock) Lock(ctx context.Context) error {
+	if l.opt.Fair {
+		count, err := l.fairLock(ctx, l.newWaiter(), false)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAlreadyLocked
+		}
+		return nil
+	}
+	if l.opt.Reentrant {
+		res, err := reentrantLockScript.Run(ctx, l.Client(), []string{l.Key(), l.FencingKey()}, l.ClientID(), l.MaxTTL().Milliseconds(), boolArg(l.opt.FencingToken)).Slice()
+		if err != nil {
+			return err
+		}
+		count, token, err := parseAcquireResult(res)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrAlreadyLocked
+		}
+		l.setToken(token)
+		if count == 1 {
+			l.startWatchDog()
+		}
+		return nil
+	}
+	if l.opt.FencingToken {
+		token, err := fencingLockScript.Run(ctx, l.Client(), []string{l.Key(), l.FencingKey()}, l.ClientID(), l.MaxTTL().Milliseconds()).Int64()
+		if err != nil {
+			return err
+		}
+		if token == 0 {
+			return ErrAlreadyLocked
+		}
+		l.setToken(token)
+		l.startWatchDog()
+		return nil
+	}
 	set, err := l.Client().SetNX(ctx, l.Key(), l.ClientID(), l.MaxTTL()).Result()
 	if err != nil {
 		return err
 	}
 	if set {
+		l.startWatchDog()
 		return nil
 	}
 	return ErrAlreadyLocked
 }
 
+//boolArg 将bool转换为脚本参数
+func boolArg(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+//parseAcquireResult 解析返回{持有次数,fencing token}的获取锁脚本的结果
+func parseAcquireResult(res []interface{}) (int64, int64, error) {
+	if len(res) != 2 {
+		return 0, 0, fmt.Errorf("acquire lock script results not ok")
+	}
+	count, ok := res[0].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("cannot parser acquire lock script result %v to int64", res[0])
+	}
+	token, ok := res[1].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("cannot parser acquire lock script result %v to int64", res[1])
+	}
+	return count, token, nil
+}
+
+//FencingKey fencing token计数器使用的键,与锁落在同一个集群slot
+func (l *Lock) FencingKey() string {
+	return l.SubKey("::fencing")
+}
+
+//Token 查看当前持有锁对应的fencing token,未持有锁或未启用fencing token时为0
+//下游存储可以拒绝token小于已见过的最大token的写入,以防止锁过期后仍在执行的旧持有者写入数据
+func (l *Lock) Token() int64 {
+	return atomic.LoadInt64(&l.token)
+}
+
+//FencingEnabled 是否启用了fencing token
+func (l *Lock) FencingEnabled() bool {
+	return l.opt.FencingToken
+}
+
+//setToken 记录当前持有锁对应的fencing token
+func (l *Lock) setToken(token int64) {
+	atomic.StoreInt64(&l.token, token)
+}
+
 //Unlock 释放锁,已经释放锁或无权释放锁时报错
+//可重入锁只有持有次数归零时才会真正释放
 func (l *Lock) Unlock(ctx context.Context) error {
-	// 1表示删成功
-	// 2表示key不存在
-	// 3表示key不匹配
-	lunlockscript := redis.NewScript(`
-		if redis.call("EXISTS", KEYS[1]) == 1 then 
-			if redis.call("GET", KEYS[1]) == ARGV[1] then
-				return redis.call("DEL", KEYS[1])
-			else
-				return 3
-			end
-		else
-			return 2
-		end`)
-	res, err := lunlockscript.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID()).Result()
+	script := UnlockScript
+	if l.opt.Reentrant {
+		script = reentrantUnlockScript
+	}
+	res, err := script.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID()).Result()
 	if err != nil {
 		return err
 	}
 	switch res.(int64) {
 	case 2:
 		{
+			l.stopWatchDog()
+			l.setToken(0)
 			return ErrAlreadyUnLocked
 		}
 	case 3:
 		{
+			l.stopWatchDog()
+			l.setToken(0)
 			return ErrNoRightToUnLock
 		}
+	case 4:
+		{
+			return nil
+		}
 	default:
 		{
+			l.stopWatchDog()
+			l.setToken(0)
+			l.notifyRelease(ctx)
 			return nil
 		}
 	}
 }
 
+//Extend 当前客户端持有锁时将锁的过期时间重置为MaxTTL,锁不存在或不属于当前客户端时返回ErrNotLockOwner
+func (l *Lock) Extend(ctx context.Context) error {
+	script := ExtendScript
+	if l.opt.Reentrant {
+		script = reentrantExtendScript
+	}
+	res, err := script.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID(), l.MaxTTL().Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrNotLockOwner
+	}
+	return nil
+}
+
+//startWatchDog 启动看门狗,未设置看门狗或看门狗已启动时不做处理
+func (l *Lock) startWatchDog() {
+	if !l.opt.WatchDog {
+		return
+	}
+	l.watchdogLock.Lock()
+	defer l.watchdogLock.Unlock()
+	if l.watchdogCancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.watchdogCancel = cancel
+	go l.watch(ctx)
+}
+
+//stopWatchDog 停止看门狗
+func (l *Lock) stopWatchDog() {
+	l.watchdogLock.Lock()
+	defer l.watchdogLock.Unlock()
+	if l.watchdogCancel != nil {
+		l.watchdogCancel()
+		l.watchdogCancel = nil
+	}
+}
+
+//watch 看门狗的续期循环,失去锁后自动退出
+func (l *Lock) watch(ctx context.Context) {
+	ticker := time.NewTicker(l.opt.WatchDogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			{
+				return
+			}
+		case <-ticker.C:
+			{
+				ctx1, cancel := context.WithTimeout(ctx, l.opt.WatchDogInterval)
+				err := l.Extend(ctx1)
+				cancel()
+				if err != nil {
+					if err == ErrNotLockOwner {
+						l.Logger().Warn("watchdog lost the lock")
+						l.watchdogLock.Lock()
+						//ctx未被取消说明当前看门狗仍是登记在对象上的那个
+						if ctx.Err() == nil {
+							l.watchdogCancel()
+							l.watchdogCancel = nil
+						}
+						l.watchdogLock.Unlock()
+						return
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					l.Logger().Error("watchdog extend lock get error", log.Dict{"err": err.Error()})
+				}
+			}
+		}
+	}
+}
+
 //读操作
 
 //Check 检测是否是锁定状态,true为锁定状态,false为非锁定状态
@@ -104,6 +393,31 @@ func (l *Lock) Check(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+//HoldCount 查看当前客户端持有锁的次数,非可重入锁持有时为1,未持有时为0
+func (l *Lock) HoldCount(ctx context.Context) (int64, error) {
+	if l.opt.Reentrant {
+		res, err := l.Client().HGet(ctx, l.Key(), l.ClientID()).Int64()
+		if err != nil {
+			if err == redis.Nil {
+				return 0, nil
+			}
+			return 0, err
+		}
+		return res, nil
+	}
+	res, err := l.Client().Get(ctx, l.Key()).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if res == l.ClientID() {
+		return 1, nil
+	}
+	return 0, nil
+}
+
 //Wait 等待锁释放
 func (l *Lock) Wait(ctx context.Context) error {
 loop:
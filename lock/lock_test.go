@@ -216,3 +216,297 @@ func Test_lock_waitLock_maxttl(t *testing.T) {
 	}
 	assert.Equal(t, false, locked)
 }
+
+func Test_new_Lock_watchdog_err(t *testing.T) {
+	// 准备工作
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	_, err := New(ck, WithWatchDog(0))
+	assert.Equal(t, ErrWatchDogNeedMaxTTL, err)
+	_, err = New(ck, WithMaxTTL(time.Second), WithWatchDog(2*time.Second))
+	assert.Equal(t, ErrWatchDogIntervalTooLong, err)
+}
+
+func Test_lock_reentrant(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	lock, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key), WithReentrant())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	lock2, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key), WithReentrant())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock2 error")
+	}
+	for i := 0; i < 3; i++ {
+		err = lock.Lock(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "lock error")
+		}
+	}
+	count, err := lock.HoldCount(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock HoldCount error")
+	}
+	assert.Equal(t, int64(3), count)
+	err = lock2.Lock(ctx)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	err = lock2.Unlock(ctx)
+	assert.Equal(t, ErrNoRightToUnLock, err)
+	for i := 0; i < 3; i++ {
+		locked, err := lock.Check(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "is locked error")
+		}
+		assert.Equal(t, true, locked)
+		err = lock.Unlock(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "unlock error")
+		}
+	}
+	locked, err := lock.Check(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "is locked error")
+	}
+	assert.Equal(t, false, locked)
+	err = lock.Unlock(ctx)
+	assert.Equal(t, ErrAlreadyUnLocked, err)
+	err = lock2.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 lock error")
+	}
+	defer lock2.Unlock(ctx)
+}
+
+func Test_lock_watchdog(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	lock, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(2*time.Second), WithWatchDog(500*time.Millisecond))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	lock2, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(2*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock2 error")
+	}
+	err = lock.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock error")
+	}
+	time.Sleep(5 * time.Second)
+	err = lock2.Lock(ctx)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	count, err := lock.HoldCount(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock HoldCount error")
+	}
+	assert.Equal(t, int64(1), count)
+	err = lock.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "unlock error")
+	}
+	err = lock2.Extend(ctx)
+	assert.Equal(t, ErrNotLockOwner, err)
+	err = lock2.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 lock error")
+	}
+	defer lock2.Unlock(ctx)
+}
+
+func Test_lock_Acquire(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	lock, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	lock2, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock2 error")
+	}
+	err = lock.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock error")
+	}
+	go func() {
+		time.Sleep(2 * time.Second)
+		lock.Unlock(ctx)
+	}()
+	err = lock2.TryLockFor(ctx, 500*time.Millisecond)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	t1 := time.Now().Unix()
+	err = lock2.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 acquire error")
+	}
+	t2 := time.Now().Unix()
+	assert.LessOrEqual(t, int64(1), t2-t1)
+	count, err := lock2.HoldCount(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 HoldCount error")
+	}
+	assert.Equal(t, int64(1), count)
+	err = lock2.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 unlock error")
+	}
+}
+
+func Test_lock_fair_Acquire(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	holder, err := New(ck, WithClientID("client00"), WithSpecifiedKey(key), WithFair())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	err = holder.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "holder acquire error")
+	}
+	order := make(chan string, 3)
+	for _, id := range []string{"client01", "client02", "client03"} {
+		l, err := New(ck, WithClientID(id), WithSpecifiedKey(key), WithFair())
+		if err != nil {
+			assert.FailNow(t, err.Error(), "new lock error")
+		}
+		go func(l *Lock) {
+			err := l.Acquire(ctx)
+			if err != nil {
+				log.Error("acquire error", log.Dict{"err": err.Error()})
+				return
+			}
+			order <- l.ClientID()
+			time.Sleep(100 * time.Millisecond)
+			l.Unlock(ctx)
+		}(l)
+		time.Sleep(200 * time.Millisecond)
+	}
+	err = holder.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "holder unlock error")
+	}
+	for _, id := range []string{"client01", "client02", "client03"} {
+		select {
+		case got := <-order:
+			assert.Equal(t, id, got)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "fair acquire timeout")
+		}
+	}
+}
+
+func Test_lock_fencing_token(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	lock, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(time.Second), WithFencingToken())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	lock2, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(time.Second), WithFencingToken(), WithReentrant())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock2 error")
+	}
+	assert.Equal(t, int64(0), lock.Token())
+	err = lock.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock error")
+	}
+	token1 := lock.Token()
+	assert.Less(t, int64(0), token1)
+	//锁过期后其他客户端获得更大的token
+	time.Sleep(1500 * time.Millisecond)
+	err = lock2.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 lock error")
+	}
+	token2 := lock2.Token()
+	assert.Less(t, token1, token2)
+	err = lock2.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock2 relock error")
+	}
+	assert.Equal(t, token2, lock2.Token())
+	lock2.Unlock(ctx)
+	lock2.Unlock(ctx)
+	assert.Equal(t, int64(0), lock2.Token())
+}
+
+func Test_lock_fair_Lock(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_lock"
+	holder, err := New(ck, WithClientID("client00"), WithSpecifiedKey(key), WithFair())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	//锁的键不随设置改变,附属键使用锁的键作为hash tag
+	assert.Equal(t, "test_lock", holder.Key())
+	assert.Equal(t, "{test_lock}::queue", holder.QueueKey())
+	assert.Equal(t, "{test_lock}::fencing", holder.FencingKey())
+	tagged, err := New(ck, WithSpecifiedKey("{test}_lock"), WithFair())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	assert.Equal(t, "{test}_lock::queue", tagged.QueueKey())
+	//公平锁与普通锁使用同一个键,互相排斥
+	plain, err := New(ck, WithClientID("client03"), WithSpecifiedKey(key))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	err = holder.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "holder lock error")
+	}
+	assert.Equal(t, ErrAlreadyLocked, plain.Lock(ctx))
+	waiter, err := New(ck, WithClientID("client01"), WithSpecifiedKey(key), WithFair())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- waiter.Acquire(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	//有等待者排队时Lock不能插队,失败也不会进入队列
+	other, err := New(ck, WithClientID("client02"), WithSpecifiedKey(key), WithFair())
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new lock error")
+	}
+	assert.Equal(t, ErrAlreadyLocked, other.Lock(ctx))
+	err = holder.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "holder unlock error")
+	}
+	assert.Equal(t, ErrAlreadyLocked, other.Lock(ctx))
+	select {
+	case err := <-acquired:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "fair acquire timeout")
+	}
+	qlen, err := ck.LLen(ctx, holder.QueueKey()).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "llen error")
+	}
+	assert.Equal(t, int64(0), qlen)
+	err = waiter.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "waiter unlock error")
+	}
+	assert.NoError(t, other.Lock(ctx))
+}
@@ -10,13 +10,22 @@ import (
 )
 
 type Options struct {
-	CheckPeriod    time.Duration                                //等待的轮询间隔
-	MiddlewareOpts []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
-	ClientIDOpts   []optparams.Option[clientIdhelper.Options]   //初始化clientID的配置
+	CheckPeriod      time.Duration                                //等待的轮询间隔,同时也是获取锁时退避等待的初始间隔
+	MaxBackoff       time.Duration                                //获取锁时退避等待的最大间隔
+	Fair             bool                                         //是否使用公平模式获取锁
+	Reentrant        bool                                         //是否为可重入锁
+	WatchDog         bool                                         //是否启用看门狗自动续期
+	WatchDogInterval time.Duration                                //看门狗的续期间隔,为0时使用MaxTTL的1/3
+	FencingToken     bool                                         //是否在获得锁时生成fencing token,仅对Lock有效
+	Permits          int64                                        //信号量的许可数,仅对Semaphore有效
+	MiddlewareOpts   []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+	ClientIDOpts     []optparams.Option[clientIdhelper.Options]   //初始化clientID的配置
 }
 
 var defaultOptions = Options{
 	CheckPeriod:    500 * time.Microsecond,
+	MaxBackoff:     500 * time.Millisecond,
+	Permits:        1,
 	MiddlewareOpts: []optparams.Option[middlewarehelper.Options]{},
 	ClientIDOpts:   []optparams.Option[clientIdhelper.Options]{},
 }
@@ -28,6 +37,51 @@ func WithCheckPeriod(checkperiod time.Duration) optparams.Option[Options] {
 	})
 }
 
+//WithMaxBackoff 锁的设置项,设置获取锁时退避等待的最大间隔
+func WithMaxBackoff(maxBackoff time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.MaxBackoff = maxBackoff
+	})
+}
+
+//WithFair 锁的设置项,使用公平模式获取锁,等待者在redis的list中排队,锁释放时通过pubsub唤醒等待者
+func WithFair() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Fair = true
+	})
+}
+
+//WithReentrant 锁的设置项,设置为可重入锁,同一ClientID可以多次获得锁,获得几次就需要释放几次
+func WithReentrant() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Reentrant = true
+	})
+}
+
+//WithWatchDog 锁的设置项,启用看门狗,持有锁期间会定时为锁续期,必须设置MaxTTL
+//@params interval time.Duration 续期间隔,为0时使用MaxTTL的1/3
+func WithWatchDog(interval time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.WatchDog = true
+		o.WatchDogInterval = interval
+	})
+}
+
+//WithFencingToken 锁的设置项,获得锁时使用与锁同名加`::fencing`后缀的计数器生成单调递增的fencing token,仅对Lock有效
+//计数器的键使用锁的键作为hash tag,与锁在同一个集群slot
+func WithFencingToken() optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.FencingToken = true
+	})
+}
+
+//WithPermits 信号量的设置项,设置同时可以持有的许可数,仅对Semaphore有效
+func WithPermits(permits int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Permits = permits
+	})
+}
+
 //m 使用optparams.Option[clientIdhelper.Options]设置客户端ID属性
 func c(opts ...optparams.Option[clientIdhelper.Options]) optparams.Option[Options] {
 	return optparams.NewFuncOption(func(o *Options) {
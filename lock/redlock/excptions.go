@@ -0,0 +1,11 @@
+package redlock
+
+import (
+	"errors"
+)
+
+//ErrNeedClients 至少需要一个redis客户端
+var ErrNeedClients = errors.New("need at least one client")
+
+//ErrNeedMaxTTL redlock必须设置MaxTTL
+var ErrNeedMaxTTL = errors.New("redlock must set MaxTTL")
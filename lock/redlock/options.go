@@ -0,0 +1,100 @@
+package redlock
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+)
+
+type Options struct {
+	CheckPeriod      time.Duration                                //等待的轮询间隔,同时也是获取锁时退避等待的初始间隔
+	MaxBackoff       time.Duration                                //获取锁时退避等待的最大间隔
+	NodeTimeout      time.Duration                                //请求单个节点的超时时间,应远小于MaxTTL
+	ClockDriftFactor float64                                      //时钟漂移系数,锁的有效时间会扣除MaxTTL*ClockDriftFactor
+	MiddlewareOpts   []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+	ClientIDOpts     []optparams.Option[clientIdhelper.Options]   //初始化clientID的配置
+}
+
+var defaultOptions = Options{
+	CheckPeriod:      500 * time.Microsecond,
+	MaxBackoff:       500 * time.Millisecond,
+	NodeTimeout:      50 * time.Millisecond,
+	ClockDriftFactor: 0.01,
+	MiddlewareOpts:   []optparams.Option[middlewarehelper.Options]{},
+	ClientIDOpts:     []optparams.Option[clientIdhelper.Options]{},
+}
+
+//WithCheckPeriod 锁的设置项,设置检查锁状态的轮询间隔时间
+func WithCheckPeriod(checkperiod time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.CheckPeriod = checkperiod
+	})
+}
+
+//WithMaxBackoff 锁的设置项,设置获取锁时退避等待的最大间隔
+func WithMaxBackoff(maxBackoff time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.MaxBackoff = maxBackoff
+	})
+}
+
+//WithNodeTimeout 锁的设置项,设置请求单个节点的超时时间
+func WithNodeTimeout(timeout time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.NodeTimeout = timeout
+	})
+}
+
+//WithClockDriftFactor 锁的设置项,设置时钟漂移系数,默认0.01
+func WithClockDriftFactor(factor float64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.ClockDriftFactor = factor
+	})
+}
+
+//c 使用optparams.Option[clientIdhelper.Options]设置客户端ID属性
+func c(opts ...optparams.Option[clientIdhelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.ClientIDOpts == nil {
+			o.ClientIDOpts = []optparams.Option[clientIdhelper.Options]{}
+		}
+		o.ClientIDOpts = append(o.ClientIDOpts, opts...)
+	})
+}
+
+//WithClientID 设置客户端id
+func WithClientID(key string) optparams.Option[Options] {
+	return c(clientIdhelper.WithClientID(key))
+}
+
+//m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
+func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.MiddlewareOpts == nil {
+			o.MiddlewareOpts = []optparams.Option[middlewarehelper.Options]{}
+		}
+		o.MiddlewareOpts = append(o.MiddlewareOpts, opts...)
+	})
+}
+
+//WithSpecifiedKey 中间件通用设置,指定使用的键,注意设置key后namespace将失效
+func WithSpecifiedKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithSpecifiedKey(key))
+}
+
+//WithKey 中间件通用设置,指定使用的键,注意设置后namespace依然有效
+func WithKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithKey(key))
+}
+
+//WithNamespace 中间件通用设置,指定锁的命名空间
+func WithNamespace(ns ...string) optparams.Option[Options] {
+	return m(middlewarehelper.WithNamespace(ns...))
+}
+
+//WithMaxTTL 设置锁的过期时间,必须设置
+func WithMaxTTL(maxTTL time.Duration) optparams.Option[Options] {
+	return m(middlewarehelper.WithMaxTTL(maxTTL))
+}
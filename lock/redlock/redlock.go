@@ -0,0 +1,283 @@
+//Package redlock 基于多个独立redis节点的分布式锁实现
+//算法参考redis官方的Redlock,在超过半数节点上获得锁且扣除耗时和时钟漂移后仍有剩余有效时间才算获得锁
+//释放锁时在所有节点上使用lock.UnlockScript校验持有者后释放
+package redlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/lock"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//Redlock 多节点分布式锁结构
+type Redlock struct {
+	opt  Options
+	clis []redis.UniversalClient
+	meta *middlewarehelper.MiddleWareAbc
+	*clientIdhelper.ClientIDAbc
+	validityLock sync.RWMutex
+	validUntil   time.Time
+}
+
+//New 新建一个多节点锁对象
+//@params clis []redis.UniversalClient 各个独立redis节点的客户端
+func New(clis []redis.UniversalClient, opts ...optparams.Option[Options]) (*Redlock, error) {
+	if len(clis) == 0 {
+		return nil, ErrNeedClients
+	}
+	l := new(Redlock)
+	l.opt = defaultOptions
+	optparams.GetOption(&l.opt, opts...)
+	if l.opt.CheckPeriod < lock.MiniCheckPeriod {
+		return nil, lock.ErrCheckPeriodLessThan100Microsecond
+	}
+	if l.opt.MaxBackoff < l.opt.CheckPeriod {
+		return nil, lock.ErrMaxBackoffLessThanCheckPeriod
+	}
+	meta, err := middlewarehelper.New(clis[0], "lock", l.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if meta.MaxTTL() <= 0 {
+		return nil, ErrNeedMaxTTL
+	}
+	cid, err := clientIdhelper.New(l.opt.ClientIDOpts...)
+	if err != nil {
+		return nil, err
+	}
+	l.clis = clis
+	l.meta = meta
+	l.ClientIDAbc = cid
+	return l, nil
+}
+
+//Key 查看锁在各个节点上使用的键
+func (l *Redlock) Key() string {
+	return l.meta.Key()
+}
+
+//MaxTTL 查看锁的过期时间
+func (l *Redlock) MaxTTL() time.Duration {
+	return l.meta.MaxTTL()
+}
+
+//Logger 获取锁对象的专用log
+func (l *Redlock) Logger() *log.Log {
+	return l.meta.Logger()
+}
+
+//Clients 获取锁使用的各个节点的客户端
+func (l *Redlock) Clients() []redis.UniversalClient {
+	return l.clis
+}
+
+//Quorum 获得锁需要成功的最少节点数
+func (l *Redlock) Quorum() int {
+	return len(l.clis)/2 + 1
+}
+
+//Validity 查看最近一次获得的锁的剩余有效时间,未获得锁或已过期时为0
+func (l *Redlock) Validity() time.Duration {
+	l.validityLock.RLock()
+	defer l.validityLock.RUnlock()
+	d := time.Until(l.validUntil)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+//nodeResult 单个节点的执行结果
+type nodeResult struct {
+	res int64
+	err error
+}
+
+//doAll 并行在所有节点上执行fn,每个节点的请求受NodeTimeout限制
+func (l *Redlock) doAll(ctx context.Context, fn func(ctx context.Context, cli redis.UniversalClient) (int64, error)) []nodeResult {
+	results := make([]nodeResult, len(l.clis))
+	var wg sync.WaitGroup
+	for i, cli := range l.clis {
+		wg.Add(1)
+		go func(i int, cli redis.UniversalClient) {
+			defer wg.Done()
+			ctx1, cancel := context.WithTimeout(ctx, l.opt.NodeTimeout)
+			defer cancel()
+			res, err := fn(ctx1, cli)
+			results[i] = nodeResult{res: res, err: err}
+		}(i, cli)
+	}
+	wg.Wait()
+	return results
+}
+
+//写操作
+
+//Lock 尝试在所有节点上设置锁
+//超过半数节点设置成功且剩余有效时间大于0时获得锁,否则释放本次可能设置成功的节点并返回lock.ErrAlreadyLocked
+func (l *Redlock) Lock(ctx context.Context) error {
+	start := time.Now()
+	results := l.doAll(ctx, func(ctx context.Context, cli redis.UniversalClient) (int64, error) {
+		set, err := cli.SetNX(ctx, l.Key(), l.ClientID(), l.MaxTTL()).Result()
+		if err != nil || !set {
+			return 0, err
+		}
+		return 1, nil
+	})
+	succeed := 0
+	var firsterr error
+	for _, r := range results {
+		if r.err != nil {
+			if firsterr == nil {
+				firsterr = r.err
+			}
+			continue
+		}
+		succeed += int(r.res)
+	}
+	drift := time.Duration(float64(l.MaxTTL())*l.opt.ClockDriftFactor) + 2*time.Millisecond
+	validity := l.MaxTTL() - time.Since(start) - drift
+	if succeed >= l.Quorum() && validity > 0 {
+		l.validityLock.Lock()
+		l.validUntil = start.Add(l.MaxTTL() - drift)
+		l.validityLock.Unlock()
+		return nil
+	}
+	l.release(results)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if l.allFailed(results) {
+		return firsterr
+	}
+	return lock.ErrAlreadyLocked
+}
+
+//allFailed 判断是否所有节点都请求失败
+func (l *Redlock) allFailed(results []nodeResult) bool {
+	for _, r := range results {
+		if r.err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+//release 获取锁失败时释放本次可能设置成功的节点
+//请求超时或报错的节点上锁也可能已经设置成功,因此除了明确返回未设置的节点(避免误删之前已经持有的锁)外都需要释放,UnlockScript只会删除属于当前客户端的锁
+func (l *Redlock) release(results []nodeResult) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i, r := range results {
+		if r.err == nil && r.res != 1 {
+			continue
+		}
+		wg.Add(1)
+		go func(cli redis.UniversalClient) {
+			defer wg.Done()
+			ctx1, cancel := context.WithTimeout(ctx, l.opt.NodeTimeout)
+			defer cancel()
+			_, err := lock.UnlockScript.Run(ctx1, cli, []string{l.Key()}, l.ClientID()).Result()
+			if err != nil {
+				l.Logger().Warn("release redlock node get error", log.Dict{"err": err.Error()})
+			}
+		}(l.clis[i])
+	}
+	wg.Wait()
+}
+
+//Unlock 在所有节点上释放锁
+//任意节点释放成功即视为成功,否则有节点不属于当前客户端时返回lock.ErrNoRightToUnLock,都不存在时返回lock.ErrAlreadyUnLocked
+func (l *Redlock) Unlock(ctx context.Context) error {
+	l.validityLock.Lock()
+	l.validUntil = time.Time{}
+	l.validityLock.Unlock()
+	results := l.doAll(ctx, func(ctx context.Context, cli redis.UniversalClient) (int64, error) {
+		return lock.UnlockScript.Run(ctx, cli, []string{l.Key()}, l.ClientID()).Int64()
+	})
+	noright := false
+	var firsterr error
+	for _, r := range results {
+		if r.err != nil {
+			if firsterr == nil {
+				firsterr = r.err
+			}
+			continue
+		}
+		switch r.res {
+		case 1:
+			{
+				return nil
+			}
+		case 3:
+			{
+				noright = true
+			}
+		}
+	}
+	if noright {
+		return lock.ErrNoRightToUnLock
+	}
+	if l.allFailed(results) {
+		return firsterr
+	}
+	return lock.ErrAlreadyUnLocked
+}
+
+//Acquire 阻塞直到获得锁或ctx结束,使用带随机抖动的指数退避重试
+func (l *Redlock) Acquire(ctx context.Context) error {
+	return lock.RetryWithBackoff(ctx, l.opt.CheckPeriod, l.opt.MaxBackoff, lock.ErrAlreadyLocked, l.Lock)
+}
+
+//TryLockFor 在指定时间内尝试获得锁,超时仍未获得时返回lock.ErrAlreadyLocked
+//@params ctx context.Context 上下文信息,ctx结束时返回ctx的错误
+//@params timeout time.Duration 最长等待时间
+func (l *Redlock) TryLockFor(ctx context.Context, timeout time.Duration) error {
+	return lock.TryFor(ctx, timeout, lock.ErrAlreadyLocked, l.Acquire)
+}
+
+//读操作
+
+//Check 检测是否是锁定状态,超过半数节点上存在锁时为锁定状态
+func (l *Redlock) Check(ctx context.Context) (bool, error) {
+	results := l.doAll(ctx, func(ctx context.Context, cli redis.UniversalClient) (int64, error) {
+		return cli.Exists(ctx, l.Key()).Result()
+	})
+	if l.allFailed(results) {
+		return false, results[0].err
+	}
+	locked := 0
+	for _, r := range results {
+		if r.err == nil && r.res > 0 {
+			locked++
+		}
+	}
+	return locked >= l.Quorum(), nil
+}
+
+//Wait 等待锁释放
+func (l *Redlock) Wait(ctx context.Context) error {
+	for {
+		r, err := l.Check(ctx)
+		if err != nil {
+			return err
+		}
+		if !r {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			{
+				return ctx.Err()
+			}
+		case <-time.After(l.opt.CheckPeriod):
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package redlock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/redishelper/v2/lock"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+//TEST_REDIS_URLS 测试用的redis地址,可以改为多个独立的redis-server实例
+var TEST_REDIS_URLS = []string{
+	"redis://localhost:6379/3",
+	"redis://localhost:6379/4",
+	"redis://localhost:6379/5",
+}
+
+//TEST_WRONG_REDIS_URL 测试用的不可用redis地址
+const TEST_WRONG_REDIS_URL = "redis://localhost:6380"
+
+func NewBackgroundClients(t *testing.T) ([]redis.UniversalClient, context.Context) {
+	ctx := context.Background()
+	clis := []redis.UniversalClient{}
+	for _, url := range TEST_REDIS_URLS {
+		options, err := redis.ParseURL(url)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "init from url error")
+		}
+		cli := redis.NewClient(options)
+		_, err = cli.FlushDB(ctx).Result()
+		if err != nil {
+			assert.FailNow(t, err.Error(), "FlushDB error")
+		}
+		clis = append(clis, cli)
+	}
+	return clis, ctx
+}
+
+func CloseClients(clis []redis.UniversalClient) {
+	for _, cli := range clis {
+		cli.Close()
+	}
+}
+
+func Test_new_Redlock_err(t *testing.T) {
+	clis, _ := NewBackgroundClients(t)
+	defer CloseClients(clis)
+	_, err := New(nil, WithMaxTTL(time.Second))
+	assert.Equal(t, ErrNeedClients, err)
+	_, err = New(clis)
+	assert.Equal(t, ErrNeedMaxTTL, err)
+	l, err := New(clis, WithMaxTTL(time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock error")
+	}
+	assert.Equal(t, true, strings.HasPrefix(l.Key(), "redishelper::lock::"))
+	assert.Equal(t, 2, l.Quorum())
+}
+
+func Test_Redlock_Lock(t *testing.T) {
+	clis, ctx := NewBackgroundClients(t)
+	defer CloseClients(clis)
+	key := "test_redlock"
+	l1, err := New(clis, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock error")
+	}
+	l2, err := New(clis, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock2 error")
+	}
+	err = l1.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock error")
+	}
+	assert.Greater(t, l1.Validity(), time.Duration(0))
+	err = l1.Lock(ctx)
+	assert.Equal(t, lock.ErrAlreadyLocked, err)
+	locked, err := l1.Check(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "check error")
+	}
+	assert.Equal(t, true, locked)
+	err = l2.Lock(ctx)
+	assert.Equal(t, lock.ErrAlreadyLocked, err)
+	err = l2.Unlock(ctx)
+	assert.Equal(t, lock.ErrNoRightToUnLock, err)
+	err = l2.TryLockFor(ctx, 300*time.Millisecond)
+	assert.Equal(t, lock.ErrAlreadyLocked, err)
+	go func() {
+		time.Sleep(time.Second)
+		l1.Unlock(ctx)
+	}()
+	err = l2.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "acquire error")
+	}
+	err = l2.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "unlock error")
+	}
+	err = l2.Unlock(ctx)
+	assert.Equal(t, lock.ErrAlreadyUnLocked, err)
+}
+
+func Test_Redlock_quorum_with_node_down(t *testing.T) {
+	clis, ctx := NewBackgroundClients(t)
+	defer CloseClients(clis)
+	options, err := redis.ParseURL(TEST_WRONG_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	wrong := redis.NewClient(options)
+	defer wrong.Close()
+	key := "test_redlock"
+	l1, err := New([]redis.UniversalClient{clis[0], clis[1], wrong}, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock error")
+	}
+	l2, err := New([]redis.UniversalClient{clis[1], clis[2], wrong}, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock2 error")
+	}
+	err = l1.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "lock error")
+	}
+	err = l2.Lock(ctx)
+	assert.Equal(t, lock.ErrAlreadyLocked, err)
+	//l2获取失败后不能影响l1在共享节点上的锁
+	res, err := clis[1].Get(ctx, key).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "get error")
+	}
+	assert.Equal(t, "client01", res)
+	err = l1.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "unlock error")
+	}
+}
+
+//lostReplyHook 命令执行成功但返回错误,模拟请求已经到达节点但响应超时
+type lostReplyHook struct{}
+
+func (h lostReplyHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h lostReplyHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if cmd.Name() == "set" {
+		cmd.SetErr(context.DeadlineExceeded)
+	}
+	return nil
+}
+
+func (h lostReplyHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h lostReplyHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+func Test_Redlock_release_all_nodes_when_quorum_failed(t *testing.T) {
+	clis, ctx := NewBackgroundClients(t)
+	defer CloseClients(clis)
+	options, err := redis.ParseURL(TEST_WRONG_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	wrong := redis.NewClient(options)
+	defer wrong.Close()
+	//使用独立的客户端对象,钩子只影响这个节点
+	options, err = redis.ParseURL(TEST_REDIS_URLS[1])
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	lost := redis.NewClient(options)
+	defer lost.Close()
+	lost.AddHook(lostReplyHook{})
+	key := "test_redlock"
+	l, err := New([]redis.UniversalClient{clis[0], lost, wrong}, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new redlock error")
+	}
+	err = l.Lock(ctx)
+	assert.Equal(t, lock.ErrAlreadyLocked, err)
+	//设置成功的节点和响应丢失的节点上的锁都被释放
+	for _, cli := range clis[:2] {
+		n, err := cli.Exists(ctx, key).Result()
+		if err != nil {
+			assert.FailNow(t, err.Error(), "exists error")
+		}
+		assert.Equal(t, int64(0), n)
+	}
+}
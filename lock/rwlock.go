@@ -0,0 +1,269 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Golang-Tools/loggerhelper/v2"
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//rLockScript 获取读锁的脚本
+//KEYS[1]为读写锁使用的hash,KEYS[2]为写者等待标记
+//ARGV[1]为客户端id,ARGV[2]为过期时间(ms)
+// 返回0表示写锁被持有或有写者在等待
+// 返回大于0的值表示当前客户端的读锁持有次数
+var rLockScript = redis.NewScript(`
+local reader = "r:" .. ARGV[1]
+if redis.call("HGET", KEYS[1], "mode") == "write" then
+	return 0
+end
+if redis.call("EXISTS", KEYS[2]) == 1 and redis.call("HEXISTS", KEYS[1], reader) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "mode", "read")
+local count = redis.call("HINCRBY", KEYS[1], reader, 1)
+if tonumber(ARGV[2]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return count`)
+
+//rUnlockScript 释放读锁的脚本,返回值与UnlockScript一致
+// 1表示当前客户端的读锁已全部释放
+// 2表示key不存在
+// 3表示当前客户端未持有读锁
+// 4表示持有次数减1,依然持有读锁
+var rUnlockScript = redis.NewScript(`
+local reader = "r:" .. ARGV[1]
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 2
+end
+if redis.call("HEXISTS", KEYS[1], reader) == 0 then
+	return 3
+end
+if redis.call("HINCRBY", KEYS[1], reader, -1) > 0 then
+	return 4
+end
+redis.call("HDEL", KEYS[1], reader)
+if redis.call("HLEN", KEYS[1]) <= 1 then
+	redis.call("DEL", KEYS[1])
+end
+return 1`)
+
+//wLockScript 获取写锁的脚本
+//KEYS[1]为读写锁使用的hash,KEYS[2]为写者等待标记
+//ARGV[1]为客户端id,ARGV[2]为过期时间(ms),ARGV[3]为写者等待标记的过期时间(ms),为0时不设置等待标记
+// 返回0表示锁被持有
+// 返回1表示获得写锁
+var wLockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	redis.call("HSET", KEYS[1], "mode", "write", "writer", ARGV[1])
+	if tonumber(ARGV[2]) > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	if redis.call("GET", KEYS[2]) == ARGV[1] then
+		redis.call("DEL", KEYS[2])
+	end
+	return 1
+end
+if tonumber(ARGV[3]) > 0 then
+	local waiting = redis.call("GET", KEYS[2])
+	if not waiting or waiting == ARGV[1] then
+		redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[3])
+	end
+end
+return 0`)
+
+//wUnlockScript 释放写锁的脚本,返回值与UnlockScript一致
+var wUnlockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 2
+end
+if redis.call("HGET", KEYS[1], "mode") == "write" and redis.call("HGET", KEYS[1], "writer") == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 3`)
+
+//RWLock 分布式读写锁
+//读锁可以被多个客户端同时持有且同一客户端可重入,写锁只能被一个客户端持有
+//使用写者优先策略,有写者在等待时新的读者无法获得读锁,已持有读锁的客户端依然可以重入
+//RWLock的Lock,Unlock,Acquire,TryLockFor操作的是写锁,满足LockInterface接口
+type RWLock struct {
+	opt Options
+	*middlewarehelper.MiddleWareAbc
+	*clientIdhelper.ClientIDAbc
+}
+
+//NewRWLock 新建一个读写锁对象
+func NewRWLock(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*RWLock, error) {
+	l := new(RWLock)
+	l.opt = defaultOptions
+	optparams.GetOption(&l.opt, opts...)
+	if l.opt.CheckPeriod < MiniCheckPeriod {
+		return nil, ErrCheckPeriodLessThan100Microsecond
+	}
+	if l.opt.MaxBackoff < l.opt.CheckPeriod {
+		return nil, ErrMaxBackoffLessThanCheckPeriod
+	}
+	meta, err := middlewarehelper.New(cli, "rwlock", l.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	cid, err := clientIdhelper.New(l.opt.ClientIDOpts...)
+	if err != nil {
+		return nil, err
+	}
+	l.MiddleWareAbc = meta
+	l.ClientIDAbc = cid
+	return l, nil
+}
+
+//writerWaitingKey 写者等待标记使用的键,与读写锁落在同一个集群slot
+func (l *RWLock) writerWaitingKey() string {
+	return l.SubKey("::writer_waiting")
+}
+
+//unlockResult 将释放锁脚本的返回值转换为错误
+func unlockResult(res int64) error {
+	switch res {
+	case 2:
+		{
+			return ErrAlreadyUnLocked
+		}
+	case 3:
+		{
+			return ErrNoRightToUnLock
+		}
+	default:
+		{
+			return nil
+		}
+	}
+}
+
+//写操作
+
+//RLock 获取读锁,写锁被持有或有写者在等待时返回ErrAlreadyLocked
+func (l *RWLock) RLock(ctx context.Context) error {
+	res, err := rLockScript.Run(ctx, l.Client(), []string{l.Key(), l.writerWaitingKey()}, l.ClientID(), l.MaxTTL().Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrAlreadyLocked
+	}
+	return nil
+}
+
+//RUnlock 释放一次读锁,已经释放锁或未持有读锁时报错
+func (l *RWLock) RUnlock(ctx context.Context) error {
+	res, err := rUnlockScript.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID()).Int64()
+	if err != nil {
+		return err
+	}
+	return unlockResult(res)
+}
+
+//RAcquire 阻塞直到获得读锁或ctx结束
+func (l *RWLock) RAcquire(ctx context.Context) error {
+	return RetryWithBackoff(ctx, l.opt.CheckPeriod, l.opt.MaxBackoff, ErrAlreadyLocked, l.RLock)
+}
+
+//RTryLockFor 在指定时间内尝试获得读锁,超时仍未获得时返回ErrAlreadyLocked
+func (l *RWLock) RTryLockFor(ctx context.Context, timeout time.Duration) error {
+	return TryFor(ctx, timeout, ErrAlreadyLocked, l.RAcquire)
+}
+
+//Lock 获取写锁,锁被持有时返回ErrAlreadyLocked
+func (l *RWLock) Lock(ctx context.Context) error {
+	return l.lock(ctx, 0)
+}
+
+//lock 获取写锁
+//@params waitttl int64 写者等待标记的过期时间(ms),为0时不设置等待标记
+func (l *RWLock) lock(ctx context.Context, waitttl int64) error {
+	res, err := wLockScript.Run(ctx, l.Client(), []string{l.Key(), l.writerWaitingKey()}, l.ClientID(), l.MaxTTL().Milliseconds(), waitttl).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrAlreadyLocked
+	}
+	return nil
+}
+
+//Unlock 释放写锁,已经释放锁或无权释放锁时报错
+func (l *RWLock) Unlock(ctx context.Context) error {
+	res, err := wUnlockScript.Run(ctx, l.Client(), []string{l.Key()}, l.ClientID()).Int64()
+	if err != nil {
+		return err
+	}
+	return unlockResult(res)
+}
+
+//Acquire 阻塞直到获得写锁或ctx结束
+//等待期间会设置写者等待标记阻止新的读者获得读锁,放弃等待时清除标记
+func (l *RWLock) Acquire(ctx context.Context) error {
+	waitttl := 3 * l.opt.MaxBackoff.Milliseconds()
+	err := RetryWithBackoff(ctx, l.opt.CheckPeriod, l.opt.MaxBackoff, ErrAlreadyLocked, func(ctx context.Context) error {
+		return l.lock(ctx, waitttl)
+	})
+	if err != nil {
+		ctx1, cancel := context.WithTimeout(context.Background(), l.opt.MaxBackoff)
+		defer cancel()
+		_, err1 := UnlockScript.Run(ctx1, l.Client(), []string{l.writerWaitingKey()}, l.ClientID()).Result()
+		if err1 != nil {
+			l.Logger().Warn("clean writer waiting flag get error", log.Dict{"err": err1.Error()})
+		}
+	}
+	return err
+}
+
+//TryLockFor 在指定时间内尝试获得写锁,超时仍未获得时返回ErrAlreadyLocked
+func (l *RWLock) TryLockFor(ctx context.Context, timeout time.Duration) error {
+	return TryFor(ctx, timeout, ErrAlreadyLocked, l.Acquire)
+}
+
+//读操作
+
+//Check 检测是否是锁定状态,读锁或写锁被持有时为true
+func (l *RWLock) Check(ctx context.Context) (bool, error) {
+	return l.Exists(ctx)
+}
+
+//Mode 查看当前的锁定模式,返回read,write,未锁定时返回空字符串
+func (l *RWLock) Mode(ctx context.Context) (string, error) {
+	res, err := l.Client().HGet(ctx, l.Key(), "mode").Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+		return "", err
+	}
+	return res, nil
+}
+
+//Wait 等待读锁和写锁都释放,ctx结束时返回ctx的错误
+func (l *RWLock) Wait(ctx context.Context) error {
+	for {
+		r, err := l.Check(ctx)
+		if err != nil {
+			return err
+		}
+		if !r {
+			return nil
+		}
+		timer := time.NewTimer(l.opt.CheckPeriod)
+		select {
+		case <-ctx.Done():
+			{
+				timer.Stop()
+				return ctx.Err()
+			}
+		case <-timer.C:
+		}
+	}
+}
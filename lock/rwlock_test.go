@@ -0,0 +1,127 @@
+package lock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_new_RWLock_defaultkey(t *testing.T) {
+	// 准备工作
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	l, err := NewRWLock(ck)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock error")
+	}
+	assert.Equal(t, true, strings.HasPrefix(l.Key(), "redishelper::rwlock::"))
+	assert.Equal(t, "{"+l.Key()+"}::writer_waiting", l.writerWaitingKey())
+}
+
+func Test_RWLock_read_write(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_rwlock"
+	r1, err := NewRWLock(ck, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock error")
+	}
+	r2, err := NewRWLock(ck, WithClientID("client02"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock2 error")
+	}
+	w, err := NewRWLock(ck, WithClientID("client03"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock3 error")
+	}
+	err = r1.RLock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "r1 rlock error")
+	}
+	err = r2.RLock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "r2 rlock error")
+	}
+	mode, err := r1.Mode(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "mode error")
+	}
+	assert.Equal(t, "read", mode)
+	err = w.Lock(ctx)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	err = w.Unlock(ctx)
+	assert.Equal(t, ErrNoRightToUnLock, err)
+
+	//写者等待期间新读者无法获得读锁,已持有读锁的客户端可以重入
+	go func() {
+		time.Sleep(time.Second)
+		r1.RUnlock(ctx)
+		r2.RUnlock(ctx)
+	}()
+	done := make(chan error)
+	go func() {
+		done <- w.Acquire(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	r3, err := NewRWLock(ck, WithClientID("client04"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock4 error")
+	}
+	err = r3.RLock(ctx)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	err = <-done
+	if err != nil {
+		assert.FailNow(t, err.Error(), "w acquire error")
+	}
+	mode, err = w.Mode(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "mode error")
+	}
+	assert.Equal(t, "write", mode)
+	err = r3.RTryLockFor(ctx, 300*time.Millisecond)
+	assert.Equal(t, ErrAlreadyLocked, err)
+	err = w.Unlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "w unlock error")
+	}
+	err = r3.RLock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "r3 rlock error")
+	}
+	err = r3.RUnlock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "r3 runlock error")
+	}
+	err = r3.RUnlock(ctx)
+	assert.Equal(t, ErrAlreadyUnLocked, err)
+}
+
+func Test_RWLock_Wait_ctx(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	key := "test_rwlock"
+	w, err := NewRWLock(ck, WithClientID("client01"), WithSpecifiedKey(key), WithMaxTTL(10*time.Second), WithCheckPeriod(2*time.Second), WithMaxBackoff(2*time.Second))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new rwlock error")
+	}
+	err = w.Lock(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "w lock error")
+	}
+	defer w.Unlock(ctx)
+	//ctx结束时不用等到下一次检查就返回
+	ctx1, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = w.Wait(ctx1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
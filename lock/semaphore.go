@@ -0,0 +1,172 @@
+package lock
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/clientIdhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//semaphoreAcquireScript 获取许可的脚本,使用有序集合记录租约,score为租约的过期时间(ms)
+//KEYS[1]为有序集合,ARGV[1]为租约id,ARGV[2]为许可数,ARGV[3]为租约时长(ms)
+// 返回0表示没有可用的许可
+// 返回1表示获得许可
+var semaphoreAcquireScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1`)
+
+//semaphoreRefreshScript 为未过期的租约续期的脚本
+//KEYS[1]为有序集合,ARGV[1]为租约id,ARGV[2]为租约时长(ms)
+// 返回0表示租约不存在或已过期
+// 返回1表示续期成功
+var semaphoreRefreshScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local exp = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not exp or tonumber(exp) <= now then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1`)
+
+//semaphoreCountScript 清理过期租约后返回当前持有许可的数量
+var semaphoreCountScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+return redis.call("ZCARD", KEYS[1])`)
+
+//Semaphore 分布式计数信号量
+//最多允许Permits个持有者同时持有许可,每个许可是一个以MaxTTL为时长的租约,
+//持有者崩溃未释放的许可会在租约过期后被回收
+type Semaphore struct {
+	opt Options
+	*middlewarehelper.MiddleWareAbc
+	*clientIdhelper.ClientIDAbc
+}
+
+//NewSemaphore 新建一个信号量对象,必须设置MaxTTL作为租约时长
+func NewSemaphore(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Semaphore, error) {
+	s := new(Semaphore)
+	s.opt = defaultOptions
+	optparams.GetOption(&s.opt, opts...)
+	if s.opt.CheckPeriod < MiniCheckPeriod {
+		return nil, ErrCheckPeriodLessThan100Microsecond
+	}
+	if s.opt.MaxBackoff < s.opt.CheckPeriod {
+		return nil, ErrMaxBackoffLessThanCheckPeriod
+	}
+	if s.opt.Permits <= 0 {
+		return nil, ErrPermitsMustLargerThanZero
+	}
+	meta, err := middlewarehelper.New(cli, "semaphore", s.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if meta.MaxTTL() <= 0 {
+		return nil, ErrSemaphoreNeedMaxTTL
+	}
+	cid, err := clientIdhelper.New(s.opt.ClientIDOpts...)
+	if err != nil {
+		return nil, err
+	}
+	s.MiddleWareAbc = meta
+	s.ClientIDAbc = cid
+	return s, nil
+}
+
+//Capacity 信号量的许可数
+func (s *Semaphore) Capacity() int64 {
+	return s.opt.Permits
+}
+
+//写操作
+
+//TryAcquire 尝试获取一个许可,没有可用的许可时返回ErrNoPermits
+//@returns string 租约id,以ClientID为前缀,释放和续期时使用
+func (s *Semaphore) TryAcquire(ctx context.Context) (string, error) {
+	lease := s.ClientID() + "::" + hex.EncodeToString(uuid.NewV4().Bytes())
+	res, err := semaphoreAcquireScript.Run(ctx, s.Client(), []string{s.Key()}, lease, s.opt.Permits, s.MaxTTL().Milliseconds()).Int64()
+	if err != nil {
+		return "", err
+	}
+	if res == 0 {
+		return "", ErrNoPermits
+	}
+	return lease, nil
+}
+
+//Acquire 阻塞直到获得一个许可或ctx结束
+//@returns string 租约id,释放和续期时使用
+func (s *Semaphore) Acquire(ctx context.Context) (string, error) {
+	var lease string
+	err := RetryWithBackoff(ctx, s.opt.CheckPeriod, s.opt.MaxBackoff, ErrNoPermits, func(ctx context.Context) error {
+		l, err := s.TryAcquire(ctx)
+		lease = l
+		return err
+	})
+	return lease, err
+}
+
+//TryAcquireFor 在指定时间内尝试获得一个许可,超时仍未获得时返回ErrNoPermits
+//@returns string 租约id,释放和续期时使用
+func (s *Semaphore) TryAcquireFor(ctx context.Context, timeout time.Duration) (string, error) {
+	var lease string
+	err := TryFor(ctx, timeout, ErrNoPermits, func(ctx context.Context) error {
+		l, err := s.Acquire(ctx)
+		lease = l
+		return err
+	})
+	return lease, err
+}
+
+//Release 释放许可,租约不存在或已过期时返回ErrLeaseNotExists
+//@params lease string 获取许可时得到的租约id
+func (s *Semaphore) Release(ctx context.Context, lease string) error {
+	res, err := s.Client().ZRem(ctx, s.Key(), lease).Result()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrLeaseNotExists
+	}
+	return nil
+}
+
+//Refresh 为租约续期,将租约的过期时间重置为MaxTTL,租约不存在或已过期时返回ErrLeaseNotExists
+//@params lease string 获取许可时得到的租约id
+func (s *Semaphore) Refresh(ctx context.Context, lease string) error {
+	res, err := semaphoreRefreshScript.Run(ctx, s.Client(), []string{s.Key()}, lease, s.MaxTTL().Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return ErrLeaseNotExists
+	}
+	return nil
+}
+
+//读操作
+
+//Count 回收过期租约后查看当前被持有的许可数
+func (s *Semaphore) Count(ctx context.Context) (int64, error) {
+	return semaphoreCountScript.Run(ctx, s.Client(), []string{s.Key()}).Int64()
+}
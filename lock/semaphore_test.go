@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_new_Semaphore_err(t *testing.T) {
+	// 准备工作
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	_, err := NewSemaphore(ck)
+	assert.Equal(t, ErrSemaphoreNeedMaxTTL, err)
+	_, err = NewSemaphore(ck, WithMaxTTL(time.Second), WithPermits(0))
+	assert.Equal(t, ErrPermitsMustLargerThanZero, err)
+}
+
+func Test_Semaphore(t *testing.T) {
+	// 准备工作
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	//开始测试
+	s, err := NewSemaphore(ck, WithClientID("client01"), WithSpecifiedKey("test_semaphore"), WithMaxTTL(2*time.Second), WithPermits(2))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "new semaphore error")
+	}
+	assert.Equal(t, int64(2), s.Capacity())
+	lease1, err := s.TryAcquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "acquire lease1 error")
+	}
+	lease2, err := s.TryAcquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "acquire lease2 error")
+	}
+	_, err = s.TryAcquire(ctx)
+	assert.Equal(t, ErrNoPermits, err)
+	count, err := s.Count(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "count error")
+	}
+	assert.Equal(t, int64(2), count)
+	err = s.Release(ctx, lease1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "release lease1 error")
+	}
+	err = s.Release(ctx, lease1)
+	assert.Equal(t, ErrLeaseNotExists, err)
+	lease3, err := s.TryAcquireFor(ctx, time.Second)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "acquire lease3 error")
+	}
+	_, err = s.TryAcquireFor(ctx, 300*time.Millisecond)
+	assert.Equal(t, ErrNoPermits, err)
+
+	//lease2未续期,过期后被回收
+	time.Sleep(time.Second)
+	err = s.Refresh(ctx, lease3)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "refresh lease3 error")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	err = s.Refresh(ctx, lease2)
+	assert.Equal(t, ErrLeaseNotExists, err)
+	count, err = s.Count(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "count error")
+	}
+	assert.Equal(t, int64(1), count)
+	_, err = s.Acquire(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "acquire error")
+	}
+}
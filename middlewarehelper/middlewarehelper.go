@@ -5,6 +5,7 @@ package middlewarehelper
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	log "github.com/Golang-Tools/loggerhelper/v2"
@@ -60,6 +61,28 @@ func (l *MiddleWareAbc) Key() string {
 	return l.key
 }
 
+//SubKey 生成组件的附属键,附属键与组件的键落在同一个集群slot,可以和组件的键在同一个lua脚本中使用
+//键本身不含hash tag时附属键为`{键}后缀`,redis只对hash tag中的内容计算slot,与键本身的slot一致;
+//键本身含hash tag时附属键为`键后缀`,沿用键的hash tag
+//注意键本身不含有效的hash tag但含有`}`时无法保证落在同一个slot
+//@params suffix string 附属键的后缀,例如`::fencing`
+func (l *MiddleWareAbc) SubKey(suffix string) string {
+	if HasHashTag(l.key) {
+		return l.key + suffix
+	}
+	return "{" + l.key + "}" + suffix
+}
+
+//HasHashTag 判断key是否包含redis集群的hash tag,即第一个`{`之后存在非空内容和`}`
+func HasHashTag(key string) bool {
+	start := strings.Index(key, "{")
+	if start < 0 {
+		return false
+	}
+	end := strings.Index(key[start+1:], "}")
+	return end > 0
+}
+
 //MiddlewareType 查看中间件类型
 func (l *MiddleWareAbc) MiddlewareType() string {
 	return l.middlewareType
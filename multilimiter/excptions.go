@@ -0,0 +1,26 @@
+package multilimiter
+
+import (
+	"errors"
+)
+
+//ErrNeedRules 至少需要设置一条规则
+var ErrNeedRules = errors.New("need at least one rule")
+
+//ErrRuleNameEmpty 规则名不能为空
+var ErrRuleNameEmpty = errors.New("rule name can not be empty")
+
+//ErrRuleNameDuplicate 规则名重复
+var ErrRuleNameDuplicate = errors.New("rule name duplicate")
+
+//ErrWindowMustLargerThanZero 滑动窗口的时长必须大于0
+var ErrWindowMustLargerThanZero = errors.New("window must larger than 0")
+
+//ErrMaxSizeMustLargerThanZero 窗口内允许的最大数量必须大于0
+var ErrMaxSizeMustLargerThanZero = errors.New("maxsize must larger than 0")
+
+//ErrSubjectEmpty 有按主体计数的规则时主体不能为空
+var ErrSubjectEmpty = errors.New("subject can not be empty")
+
+//ErrScriptResultNotMatch 脚本的返回值不符合预期
+var ErrScriptResultNotMatch = errors.New("script result not match")
@@ -0,0 +1,140 @@
+package multilimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+func Test_limiter_options(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	_, err := New(ck)
+	assert.Equal(t, ErrNeedRules, err)
+	_, err = New(ck, WithRule("", time.Second, 10))
+	assert.Equal(t, ErrRuleNameEmpty, err)
+	_, err = New(ck, WithRule("a", time.Second, 10), WithSubjectRule("a", time.Second, 10))
+	assert.Equal(t, ErrRuleNameDuplicate, err)
+	_, err = New(ck, WithRule("a", 0, 10))
+	assert.Equal(t, ErrWindowMustLargerThanZero, err)
+	_, err = New(ck, WithRule("a", time.Second, 0))
+	assert.Equal(t, ErrMaxSizeMustLargerThanZero, err)
+}
+
+func Test_limiter_take(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck,
+		WithSubjectRule("user_second", time.Second, 5),
+		WithSubjectRule("user_hour", time.Hour, 8),
+		WithRule("global", time.Second, 10),
+		WithSpecifiedKey("test_multilimiter"),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	assert.Equal(t, "test_multilimiter::user_second::u1", limiter.RuleKey(limiter.Rules()[0], "u1"))
+	assert.Equal(t, "test_multilimiter::global", limiter.RuleKey(limiter.Rules()[2], "u1"))
+	_, err = limiter.Take(ctx, "", 1)
+	assert.Equal(t, ErrSubjectEmpty, err)
+
+	res, err := limiter.Take(ctx, "u1", 4)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, true, res.Allowed)
+	assert.Equal(t, "", res.BlockedBy)
+	assert.Equal(t, int64(1), res.Rules["user_second"].Remaining)
+	assert.Equal(t, int64(4), res.Rules["user_hour"].Remaining)
+	assert.Equal(t, int64(6), res.Rules["global"].Remaining)
+	assert.Equal(t, int64(1), res.Reservation().Remaining)
+
+	//超出每秒的限制,所有规则都不消耗
+	res, err = limiter.Take(ctx, "u1", 2)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, false, res.Allowed)
+	assert.Equal(t, "user_second", res.BlockedBy)
+	assert.Equal(t, true, res.Rules["user_hour"].Allowed)
+	assert.Equal(t, int64(4), res.Rules["user_hour"].Remaining)
+	assert.Equal(t, int64(6), res.Rules["global"].Remaining)
+	assert.Greater(t, res.Reservation().RetryAfter, time.Duration(0))
+
+	//其他主体只受全局规则影响
+	ok, blocked, err := limiter.Allow(ctx, "u2", 5)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Allow get error")
+	}
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "", blocked)
+	ok, blocked, err = limiter.Allow(ctx, "u3", 2)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Allow get error")
+	}
+	assert.Equal(t, false, ok)
+	assert.Equal(t, "global", blocked)
+
+	//超过容量的注入永远无法成功
+	res, err = limiter.Take(ctx, "u1", 9)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, time.Duration(-1), res.Reservation().RetryAfter)
+
+	err = limiter.Reset(ctx, "u1")
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Reset get error")
+	}
+	res, err = limiter.Take(ctx, "u1", 0)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Take get error")
+	}
+	assert.Equal(t, int64(5), res.Rules["user_second"].Remaining)
+	assert.Equal(t, int64(10), res.Rules["global"].Remaining)
+}
+
+func Test_limiter_wait(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := New(ck,
+		WithSubjectRule("user_second", 500*time.Millisecond, 2),
+		WithSubjectRule("user_hour", time.Hour, 4),
+		WithSpecifiedKey("test_multilimiter"),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		err := limiter.Wait(ctx, "u1", 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Wait get error")
+		}
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	err = limiter.Wait(tctx, "u1", 1)
+	assert.Error(t, err)
+}
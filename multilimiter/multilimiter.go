@@ -0,0 +1,265 @@
+//Package multilimiter 多规则限制器
+//一个限制器可以同时设置多条窗口时长和计数范围各不相同的规则,比如每个用户10/s且1000/h,同时全局5000/s
+//所有规则在一个lua脚本中原子的检查并消耗,任意一条规则超出限制时所有规则都不会消耗数量
+//每条规则使用滑动窗口计数算法,时间使用redis服务端的时间
+//一次检查涉及的所有键都由限制器的键派生,在集群中使用时需要在键中使用hash tag(比如WithSpecifiedKey("{api}"))保证它们在同一个slot
+package multilimiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+)
+
+//multiScript 多规则滑动窗口计数
+//KEYS为每条规则使用的哈希表,字段为窗口序号,值为窗口内的计数
+//ARGV[1]为注入的数量,之后每条规则依次为窗口时长(ms)和最大数量
+//先检查所有规则,全部通过后才为所有规则计数
+//返回{是否成功,第一条超出限制的规则序号(从1开始,没有为0),之后每条规则依次为{水位,需要等待的时间(ms,永远无法成功为-1),水位归零需要的时间(ms)}}
+var multiScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local n = tonumber(ARGV[1])
+local states = {}
+local blocked = 0
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[2 * i])
+	local max = tonumber(ARGV[2 * i + 1])
+	local cur = math.floor(now / window)
+	local curField = string.format("%d", cur)
+	local prevField = string.format("%d", cur - 1)
+	local counts = redis.call("HMGET", key, curField, prevField)
+	local curCount = tonumber(counts[1]) or 0
+	local prevCount = tonumber(counts[2]) or 0
+	local elapsed = now - cur * window
+	local level = math.ceil(prevCount * (1 - elapsed / window) + curCount)
+	local resetAfter = 0
+	if curCount > 0 then
+		resetAfter = 2 * window - elapsed
+	elseif prevCount > 0 then
+		resetAfter = window - elapsed
+	end
+	local retryAfter = 0
+	if n > 0 and level + n > max then
+		retryAfter = -1
+		if n <= max then
+			if curCount + n <= max then
+				retryAfter = (1 - (max - n - curCount) / prevCount) * window - elapsed
+			else
+				retryAfter = window - elapsed + (1 - (max - n) / curCount) * window
+			end
+			retryAfter = math.max(math.ceil(retryAfter), 1)
+		end
+		if blocked == 0 then
+			blocked = i
+		end
+	end
+	states[i] = {window, curField, prevField, level, retryAfter, resetAfter, elapsed}
+end
+local allowed = 0
+if blocked == 0 then
+	allowed = 1
+end
+local res = {allowed, blocked}
+for i, key in ipairs(KEYS) do
+	local s = states[i]
+	local level = s[4]
+	local resetAfter = s[6]
+	if allowed == 1 and n > 0 then
+		redis.call("HINCRBY", key, s[2], n)
+		for _, field in ipairs(redis.call("HKEYS", key)) do
+			if field ~= s[2] and field ~= s[3] then
+				redis.call("HDEL", key, field)
+			end
+		end
+		redis.call("PEXPIRE", key, s[1] * 2)
+		level = level + n
+		resetAfter = 2 * s[1] - s[7]
+	end
+	table.insert(res, level)
+	table.insert(res, s[5])
+	table.insert(res, resetAfter)
+end
+return res`)
+
+//Result 多规则注水的结果
+type Result struct {
+	Allowed   bool                                  //是否注水成功,所有规则都通过才会成功
+	BlockedBy string                                //第一条超出限制的规则名,注水成功时为空
+	Rules     map[string]*limiterhelper.Reservation //每条规则的结果,Allowed表示该规则是否通过
+}
+
+//Reservation 将多条规则的结果合并为一个结果
+//注水失败时返回需要等待最久的规则的结果,有规则永远无法通过时返回该规则的结果,注水成功时返回剩余容量最少的规则的结果
+func (r *Result) Reservation() *limiterhelper.Reservation {
+	var res *limiterhelper.Reservation
+	for _, rule := range r.Rules {
+		switch {
+		case res == nil:
+			res = rule
+		case !r.Allowed:
+			if rule.Allowed || res.RetryAfter < 0 {
+				continue
+			}
+			if res.Allowed || rule.RetryAfter < 0 || rule.RetryAfter > res.RetryAfter {
+				res = rule
+			}
+		case rule.Remaining < res.Remaining:
+			res = rule
+		}
+	}
+	return res
+}
+
+//Limiter 多规则限制器
+type Limiter struct {
+	opt Options
+	*middlewarehelper.MiddleWareAbc
+}
+
+//New 创建一个多规则限制器
+//@params cli redis.UniversalClient 客户端对象
+//@params opts ...optparams.Option[Options] 限制器的可设置项,需要至少使用WithRule或WithSubjectRule设置一条规则
+func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k := new(Limiter)
+	k.opt = defaultOptions
+	optparams.GetOption(&k.opt, opts...)
+	if len(k.opt.Rules) == 0 {
+		return nil, ErrNeedRules
+	}
+	names := map[string]bool{}
+	for _, rule := range k.opt.Rules {
+		if rule.Name == "" {
+			return nil, ErrRuleNameEmpty
+		}
+		if names[rule.Name] {
+			return nil, ErrRuleNameDuplicate
+		}
+		names[rule.Name] = true
+		if rule.Window.Milliseconds() <= 0 {
+			return nil, ErrWindowMustLargerThanZero
+		}
+		if rule.MaxSize <= 0 {
+			return nil, ErrMaxSizeMustLargerThanZero
+		}
+	}
+	m, err := middlewarehelper.New(cli, "limiter", k.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.MiddleWareAbc = m
+	return k, nil
+}
+
+//Rules 限制器的规则
+func (c *Limiter) Rules() []Rule {
+	return c.opt.Rules
+}
+
+//RuleKey 规则使用的键
+//@params rule Rule 规则
+//@params subject string 主体,全局规则会忽略
+func (c *Limiter) RuleKey(rule Rule, subject string) string {
+	if rule.PerSubject {
+		return c.Key() + "::" + rule.Name + "::" + subject
+	}
+	return c.Key() + "::" + rule.Name
+}
+
+//keys 一次检查涉及的所有键
+func (c *Limiter) keys(subject string) ([]string, error) {
+	keys := make([]string, 0, len(c.opt.Rules))
+	for _, rule := range c.opt.Rules {
+		if rule.PerSubject && subject == "" {
+			return nil, ErrSubjectEmpty
+		}
+		keys = append(keys, c.RuleKey(rule, subject))
+	}
+	return keys, nil
+}
+
+//Take 检查所有规则并注水,任意一条规则超出限制时所有规则都不会消耗数量
+//@params subject string 主体,只有全局规则时可以为空
+//@params value int64 注水量,为0时只观测各规则的水位
+func (c *Limiter) Take(ctx context.Context, subject string, value int64) (*Result, error) {
+	keys, err := c.keys(subject)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]any, 0, 1+2*len(c.opt.Rules))
+	args = append(args, value)
+	for _, rule := range c.opt.Rules {
+		args = append(args, rule.Window.Milliseconds(), rule.MaxSize)
+	}
+	res, err := multiScript.Run(ctx, c.Client(), keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 2+3*len(c.opt.Rules) {
+		return nil, ErrScriptResultNotMatch
+	}
+	result := &Result{
+		Allowed: res[0] == 1,
+		Rules:   make(map[string]*limiterhelper.Reservation, len(c.opt.Rules)),
+	}
+	if res[1] > 0 {
+		result.BlockedBy = c.opt.Rules[res[1]-1].Name
+	}
+	for i, rule := range c.opt.Rules {
+		level, retry, reset := res[2+3*i], res[3+3*i], res[4+3*i]
+		retryAfter := time.Duration(retry) * time.Millisecond
+		if retry < 0 {
+			retryAfter = -1
+		}
+		result.Rules[rule.Name] = limiterhelper.NewReservation(retry == 0, rule.MaxSize, level, retryAfter, time.Duration(reset)*time.Millisecond)
+	}
+	return result, nil
+}
+
+//Allow 检查所有规则并注水
+//@params subject string 主体,只有全局规则时可以为空
+//@params value int64 注水量
+//@returns bool 是否注水成功
+//@returns string 第一条超出限制的规则名,注水成功时为空
+func (c *Limiter) Allow(ctx context.Context, subject string, value int64) (bool, string, error) {
+	res, err := c.Take(ctx, subject, value)
+	if err != nil {
+		return true, "", err
+	}
+	return res.Allowed, res.BlockedBy, nil
+}
+
+//Wait 阻塞直到所有规则都通过或ctx结束
+//@params subject string 主体,只有全局规则时可以为空
+//@params value int64 注水量
+func (c *Limiter) Wait(ctx context.Context, subject string, value int64) error {
+	return limiterhelper.Wait(ctx, func(ctx context.Context, n int64) (*limiterhelper.Reservation, error) {
+		res, err := c.Take(ctx, subject, n)
+		if err != nil {
+			return nil, err
+		}
+		return res.Reservation(), nil
+	}, value)
+}
+
+//Reset 重置全局规则和指定主体的规则的计数
+//@params subject string 主体,为空时只重置全局规则
+func (c *Limiter) Reset(ctx context.Context, subject string) error {
+	keys := []string{}
+	for _, rule := range c.opt.Rules {
+		if rule.PerSubject && subject == "" {
+			continue
+		}
+		keys = append(keys, c.RuleKey(rule, subject))
+	}
+	_, err := c.Client().Del(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package multilimiter
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+)
+
+//Rule 限流规则,在Window时长的滑动窗口内最多允许MaxSize的数量
+type Rule struct {
+	Name       string        //规则名,会作为键的一部分,同一个限制器中不能重复
+	Window     time.Duration //滑动窗口的时长,精度为ms
+	MaxSize    int64         //窗口内允许的最大数量
+	PerSubject bool          //是否按主体分别计数,为false时所有主体共用一个计数
+}
+
+type Options struct {
+	Rules          []Rule                                       //限流规则,按设置的顺序检查
+	MiddlewareOpts []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+}
+
+var defaultOptions = Options{
+	Rules:          []Rule{},
+	MiddlewareOpts: []optparams.Option[middlewarehelper.Options]{},
+}
+
+//WithRule 添加一条所有主体共用计数的全局规则
+//@params name string 规则名
+//@params window time.Duration 滑动窗口的时长
+//@params maxsize int64 窗口内允许的最大数量
+func WithRule(name string, window time.Duration, maxsize int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Rules = append(o.Rules, Rule{Name: name, Window: window, MaxSize: maxsize})
+	})
+}
+
+//WithSubjectRule 添加一条按主体分别计数的规则
+//@params name string 规则名
+//@params window time.Duration 滑动窗口的时长
+//@params maxsize int64 每个主体窗口内允许的最大数量
+func WithSubjectRule(name string, window time.Duration, maxsize int64) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Rules = append(o.Rules, Rule{Name: name, Window: window, MaxSize: maxsize, PerSubject: true})
+	})
+}
+
+//m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
+func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.MiddlewareOpts == nil {
+			o.MiddlewareOpts = []optparams.Option[middlewarehelper.Options]{}
+		}
+		o.MiddlewareOpts = append(o.MiddlewareOpts, opts...)
+	})
+}
+
+//WithSpecifiedKey 中间件通用设置,指定使用的键,注意设置key后namespace将失效
+func WithSpecifiedKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithSpecifiedKey(key))
+}
+
+//WithKey 中间件通用设置,指定使用的键,注意设置后namespace依然有效
+func WithKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithKey(key))
+}
+
+//WithNamespace 中间件通用设置,指定锁的命名空间
+func WithNamespace(ns ...string) optparams.Option[Options] {
+	return m(middlewarehelper.WithNamespace(ns...))
+}
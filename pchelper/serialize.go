@@ -0,0 +1,42 @@
+package pchelper
+
+import (
+	msgpack "github.com/vmihailenco/msgpack/v5"
+)
+
+//Marshal 使用指定的序列化协议序列化值
+//与ToBytes不同,字符串,数字等基础类型也会按协议序列化,可以用Unmarshal还原
+func Marshal(spt SerializeProtocolType, v interface{}) ([]byte, error) {
+	switch spt {
+	case SerializeProtocol_JSON:
+		{
+			return json.Marshal(v)
+		}
+	case SerializeProtocol_MSGPACK:
+		{
+			return msgpack.Marshal(v)
+		}
+	default:
+		{
+			return nil, ErrUnSupportSerializeProtocol
+		}
+	}
+}
+
+//Unmarshal 使用指定的序列化协议将字节串反序列化到v,v必须为指针
+func Unmarshal(spt SerializeProtocolType, data []byte, v interface{}) error {
+	switch spt {
+	case SerializeProtocol_JSON:
+		{
+			return json.Unmarshal(data, v)
+		}
+	case SerializeProtocol_MSGPACK:
+		{
+			return msgpack.Unmarshal(data, v)
+		}
+	default:
+		{
+			return ErrUnSupportSerializeProtocol
+		}
+	}
+}
@@ -4,6 +4,7 @@ package pubsubhelper
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/Golang-Tools/optparams"
 
@@ -15,6 +16,7 @@ import (
 //Consumer 发布订阅器消费者对象
 type Consumer struct {
 	cli          redis.UniversalClient
+	listenLock   sync.Mutex //保护listenPubsub
 	listenPubsub *redis.PubSub
 	*clientIdhelper.ClientIDAbc
 	*pchelper.ConsumerABC
@@ -47,18 +49,40 @@ func (s *Consumer) Client() redis.UniversalClient {
 //@params topics string 监听的topic,复数topic用`,`隔开
 //@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
 func (s *Consumer) Listen(topics string, opts ...optparams.Option[pchelper.ListenOptions]) error {
+	return s.ListenWithContext(context.Background(), topics, opts...)
+}
+
+//ListenWithContext 监听发布订阅器,ctx结束或调用StopListening时停止监听
+//与Listen不同,在监听开始之前ctx就已结束时也能保证监听会停止
+//@params ctx context.Context 监听的上下文
+//@params topics string 监听的topic,复数topic用`,`隔开
+//@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
+func (s *Consumer) ListenWithContext(ctx context.Context, topics string, opts ...optparams.Option[pchelper.ListenOptions]) error {
+	listenopt := pchelper.DefaultListenOpt
+	optparams.GetOption(&listenopt, opts...)
+	topic_slice := strings.Split(topics, ",")
+	s.listenLock.Lock()
 	if s.listenPubsub != nil {
+		s.listenLock.Unlock()
 		return ErrPubSubAlreadyListened
 	}
+	pubsub := s.cli.Subscribe(ctx, topic_slice...)
+	s.listenPubsub = pubsub
+	s.listenLock.Unlock()
+	stopped := make(chan struct{})
 	defer func() {
+		close(stopped)
+		s.listenLock.Lock()
 		s.listenPubsub = nil
+		s.listenLock.Unlock()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			pubsub.Close()
+		case <-stopped:
+		}
 	}()
-	listenopt := pchelper.DefaultListenOpt
-	optparams.GetOption(&listenopt, opts...)
-	topic_slice := strings.Split(topics, ",")
-	ctx := context.Background()
-	pubsub := s.cli.Subscribe(ctx, topic_slice...)
-	s.listenPubsub = pubsub
 	ch := pubsub.Channel()
 	for m := range ch {
 		topic := m.Channel
@@ -78,6 +102,8 @@ func (s *Consumer) Listen(topics string, opts ...optparams.Option[pchelper.Liste
 
 //StopListening 停止监听
 func (s *Consumer) StopListening() error {
+	s.listenLock.Lock()
+	defer s.listenLock.Unlock()
 	if s.listenPubsub == nil {
 		return ErrPubSubNotListeningYet
 	}
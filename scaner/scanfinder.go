@@ -15,6 +15,7 @@ type Scanner struct {
 type ScannerINterface interface {
 	Find(ctx context.Context, partten string) ([]string, error)
 	FindOne(ctx context.Context, partten string) (string, error)
+	ForEachPage(ctx context.Context, partten string, fn func(keys []string) error) error
 }
 
 func New(cli redis.UniversalClient, opts ...optparams.Option[Options]) *Scanner {
@@ -60,3 +61,26 @@ func (s *Scanner) findOneIter(ctx context.Context, partten string, cursor uint64
 func (s *Scanner) FindOne(ctx context.Context, partten string) (string, error) {
 	return s.findOneIter(ctx, partten, 0, s.opt.StepSize)
 }
+
+func (s *Scanner) forEachPageIter(ctx context.Context, partten string, cursor uint64, stepsize int64, fn func(keys []string) error) error {
+	keys, newcursor, err := s.cli.Scan(ctx, cursor, partten, stepsize).Result()
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		err = fn(keys)
+		if err != nil {
+			return err
+		}
+	}
+	if newcursor != 0 {
+		return s.forEachPageIter(ctx, partten, newcursor, stepsize, fn)
+	}
+	return nil
+}
+
+//ForEachPage 分页遍历满足匹配的key,每取到一页就调用一次fn,不会把所有key都加载到内存
+//fn返回错误时停止遍历并返回该错误
+func (s *Scanner) ForEachPage(ctx context.Context, partten string, fn func(keys []string) error) error {
+	return s.forEachPageIter(ctx, partten, 0, s.opt.StepSize, fn)
+}
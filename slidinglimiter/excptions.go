@@ -0,0 +1,11 @@
+package slidinglimiter
+
+import (
+	"errors"
+)
+
+//ErrWindowMustLargerThanZero 滑动窗口的时长必须大于0
+var ErrWindowMustLargerThanZero = errors.New("window must larger than 0")
+
+//ErrScriptResultNotMatch 脚本的返回值不符合预期
+var ErrScriptResultNotMatch = errors.New("script result not match")
@@ -0,0 +1,252 @@
+package slidinglimiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST_REDIS_URL 测试用的redis地址
+const TEST_REDIS_URL = "redis://localhost:6379"
+
+var _ limiterhelper.LimiterInterface = (*Limiter)(nil)
+
+func NewBackgroundClient(t *testing.T) (redis.UniversalClient, context.Context) {
+	options, err := redis.ParseURL(TEST_REDIS_URL)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "init from url error")
+	}
+	cli := redis.NewClient(options)
+	ctx := context.Background()
+	_, err = cli.FlushDB(ctx).Result()
+	if err != nil {
+		assert.FailNow(t, err.Error(), "FlushDB error")
+	}
+	return cli, ctx
+}
+
+var constructors = map[string]func(redis.UniversalClient, ...optparams.Option[Options]) (*Limiter, error){
+	"log":     NewLog,
+	"counter": NewCounter,
+}
+
+func Test_limiter_options(t *testing.T) {
+	ck, _ := NewBackgroundClient(t)
+	defer ck.Close()
+	for name, newfunc := range constructors {
+		_, err := newfunc(ck, WithWarningSize(120))
+		assert.Equal(t, limiterhelper.ErrLimiterMaxSizeMustLargerThanWaringSize, err, name)
+		_, err = newfunc(ck, WithWindow(0))
+		assert.Equal(t, ErrWindowMustLargerThanZero, err, name)
+	}
+}
+
+func Test_limiter_interface(t *testing.T) {
+	for name, newfunc := range constructors {
+		ck, ctx := NewBackgroundClient(t)
+		limiter, err := newfunc(ck, WithWindow(5*time.Second), WithMaxSize(120), WithSpecifiedKey("test_slidinglimiter"))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter new get error")
+		}
+		assert.Equal(t, int64(120), limiter.Capacity(), name)
+		wl, err := limiter.WaterLevel(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+		}
+		assert.Equal(t, int64(0), wl, name)
+		res, err := limiter.Flood(ctx, 11)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res, name)
+		wl, err = limiter.WaterLevel(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+		}
+		assert.Equal(t, int64(11), wl, name)
+		//超出容量的注入不会占用数量
+		res, err = limiter.Flood(ctx, 110)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, false, res, name)
+		wl, err = limiter.WaterLevel(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+		}
+		assert.Equal(t, int64(11), wl, name)
+		res, err = limiter.Flood(ctx, 109)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res, name)
+		isfull, err := limiter.IsFull(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter IsFull get error")
+		}
+		assert.Equal(t, true, isfull, name)
+		err = limiter.Reset(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Reset get error")
+		}
+		isfull, err = limiter.IsFull(ctx)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter IsFull get error")
+		}
+		assert.Equal(t, false, isfull, name)
+		ck.Close()
+	}
+}
+
+func Test_log_limiter_sliding(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := NewLog(ck, WithWindow(time.Second), WithMaxSize(10), WithWarningSize(5), WithSpecifiedKey("test_slidinglimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	for i := 0; i < 5; i++ {
+		res, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res)
+	}
+	time.Sleep(600 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		res, err := limiter.Flood(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Flood get error")
+		}
+		assert.Equal(t, true, res)
+	}
+	res, err := limiter.Flood(ctx, 1)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, false, res)
+	//第一批滑出窗口后只释放第一批的数量
+	time.Sleep(500 * time.Millisecond)
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Equal(t, int64(5), wl)
+	res, err = limiter.Flood(ctx, 6)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, false, res)
+	res, err = limiter.Flood(ctx, 5)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, res)
+}
+
+func Test_counter_limiter_sliding(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := NewCounter(ck, WithWindow(time.Second), WithMaxSize(100), WithSpecifiedKey("test_slidinglimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	//对齐到窗口开始
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	res, err := limiter.Flood(ctx, 100)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, res)
+	//进入下一个窗口的前段,上一个窗口的计数依然占据大部分数量,不会放过两倍的流量
+	time.Sleep(1100 * time.Millisecond)
+	wl, err := limiter.WaterLevel(ctx)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter WaterLevel get error")
+	}
+	assert.Greater(t, wl, int64(70))
+	res, err = limiter.Flood(ctx, 50)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, false, res)
+	time.Sleep(500 * time.Millisecond)
+	res, err = limiter.Flood(ctx, 50)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, true, res)
+}
+
+func Test_limiter_hooks(t *testing.T) {
+	ck, ctx := NewBackgroundClient(t)
+	defer ck.Close()
+	limiter, err := NewCounter(ck, WithWindow(5*time.Second), WithSpecifiedKey("test_slidinglimiter"))
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter new get error")
+	}
+	warning := 0
+	full := 0
+	limiter.OnWarning(func(res, maxsize int64) error {
+		warning++
+		return nil
+	})
+	limiter.OnFull(func(res, maxsize int64) error {
+		full++
+		return nil
+	})
+	_, err = limiter.Flood(ctx, 80)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	_, err = limiter.Flood(ctx, 21)
+	if err != nil {
+		assert.FailNow(t, err.Error(), "limiter Flood get error")
+	}
+	assert.Equal(t, 1, warning)
+	assert.Equal(t, 1, full)
+}
+
+func Test_limiter_take(t *testing.T) {
+	for name, newfunc := range constructors {
+		ck, ctx := NewBackgroundClient(t)
+		limiter, err := newfunc(ck, WithWindow(time.Second), WithMaxSize(10), WithWarningSize(5), WithSpecifiedKey("test_slidinglimiter"))
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter new get error")
+		}
+		var _ limiterhelper.TakerInterface = limiter
+		res, err := limiter.Take(ctx, 10)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Take get error")
+		}
+		assert.Equal(t, true, res.Allowed, name)
+		assert.Equal(t, int64(0), res.Remaining, name)
+		assert.Equal(t, time.Duration(0), res.RetryAfter, name)
+		res, err = limiter.Take(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Take get error")
+		}
+		assert.Equal(t, false, res.Allowed, name)
+		assert.Greater(t, res.RetryAfter, time.Duration(0), name)
+		assert.LessOrEqual(t, res.RetryAfter, 2*time.Second, name)
+		assert.True(t, res.ResetAt.After(time.Now()), name)
+		//等待建议的时间后可以注水
+		time.Sleep(res.RetryAfter + 20*time.Millisecond)
+		res, err = limiter.Take(ctx, 1)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Take get error")
+		}
+		assert.Equal(t, true, res.Allowed, name)
+		res, err = limiter.Take(ctx, 11)
+		if err != nil {
+			assert.FailNow(t, err.Error(), "limiter Take get error")
+		}
+		assert.Equal(t, time.Duration(-1), res.RetryAfter, name)
+		ck.Close()
+	}
+}
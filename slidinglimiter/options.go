@@ -0,0 +1,90 @@
+package slidinglimiter
+
+import (
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/robfig/cron/v3"
+)
+
+type Options struct {
+	Window         time.Duration //滑动窗口的时长
+	LimiterOpts    []optparams.Option[limiterhelper.Options]
+	MiddlewareOpts []optparams.Option[middlewarehelper.Options] //初始化Middleware的配置
+}
+
+var defaultOptions = Options{
+	Window:         time.Second,
+	LimiterOpts:    []optparams.Option[limiterhelper.Options]{},
+	MiddlewareOpts: []optparams.Option[middlewarehelper.Options]{},
+}
+
+//WithWindow 设置滑动窗口的时长,默认1s,键的过期时间由窗口时长决定
+func WithWindow(window time.Duration) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		o.Window = window
+	})
+}
+
+//m 使用optparams.Option[middlewarehelper.Options]设置中间件属性
+func m(opts ...optparams.Option[middlewarehelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.MiddlewareOpts == nil {
+			o.MiddlewareOpts = []optparams.Option[middlewarehelper.Options]{}
+		}
+		o.MiddlewareOpts = append(o.MiddlewareOpts, opts...)
+	})
+}
+
+//WithSpecifiedKey 中间件通用设置,指定使用的键,注意设置key后namespace将失效
+func WithSpecifiedKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithSpecifiedKey(key))
+}
+
+//WithKey 中间件通用设置,指定使用的键,注意设置后namespace依然有效
+func WithKey(key string) optparams.Option[Options] {
+	return m(middlewarehelper.WithKey(key))
+}
+
+//WithNamespace 中间件通用设置,指定锁的命名空间
+func WithNamespace(ns ...string) optparams.Option[Options] {
+	return m(middlewarehelper.WithNamespace(ns...))
+}
+
+//WithTaskCron 设置定时器
+func WithTaskCron(taskCron *cron.Cron) optparams.Option[Options] {
+	return m(middlewarehelper.WithTaskCron(taskCron))
+}
+
+//l 使用optparams.Option[limiterhelper.Options]设置limiter配置
+func l(opts ...optparams.Option[limiterhelper.Options]) optparams.Option[Options] {
+	return optparams.NewFuncOption(func(o *Options) {
+		if o.LimiterOpts == nil {
+			o.LimiterOpts = []optparams.Option[limiterhelper.Options]{}
+		}
+		o.LimiterOpts = append(o.LimiterOpts, opts...)
+	})
+}
+
+//WithMaxSize 设置窗口内允许的最大数量,必须大于0
+func WithMaxSize(maxsize int64) optparams.Option[Options] {
+	return l(limiterhelper.WithMaxSize(maxsize))
+}
+
+//WithWarningSize 设置警戒水位,必须大于0
+func WithWarningSize(warningSize int64) optparams.Option[Options] {
+	return l(limiterhelper.WithWarningSize(warningSize))
+}
+
+//WithAsyncHooks 设置异步执行钩子,队列满时新触发的钩子会被丢弃
+//@params queueSize int 异步执行钩子的队列长度,必须大于0
+func WithAsyncHooks(queueSize int) optparams.Option[Options] {
+	return l(limiterhelper.WithAsyncHooks(queueSize))
+}
+
+//WithHookErrorHandler 设置钩子返回错误或panic时的处理函数,默认打印警告日志
+func WithHookErrorHandler(fn limiterhelper.HookErrorHandler) optparams.Option[Options] {
+	return l(limiterhelper.WithHookErrorHandler(fn))
+}
@@ -0,0 +1,261 @@
+//Package slidinglimiter 滑动窗口限制器
+//提供基于有序集合的滑动窗口日志限制器和基于两个相邻窗口加权计数的滑动窗口计数限制器
+//与incrlimiter的固定窗口不同,滑动窗口不会在窗口交界处放过两倍的流量,并且每次操作都在一个lua脚本中原子执行
+//窗口的计算使用客户端的时间,因此多个客户端之间需要保持时钟同步
+package slidinglimiter
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Golang-Tools/optparams"
+	"github.com/Golang-Tools/redishelper/v2/limiterhelper"
+	"github.com/Golang-Tools/redishelper/v2/middlewarehelper"
+	"github.com/go-redis/redis/v8"
+	uuid "github.com/satori/go.uuid"
+)
+
+//logScript 滑动窗口日志
+//KEYS[1]为有序集合,成员为每个计数,分数为记录时间(us)
+//ARGV[1]为当前时间(us),ARGV[2]为窗口时长(us),ARGV[3]为最大数量,ARGV[4]为注入的数量,ARGV[5]为本次注入的成员前缀
+//返回{是否成功,当前水位,需要等待的时间(ms,永远无法成功为-1),窗口内数量清零需要的时间(ms)}
+var logScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local function expireAfter(index)
+	local entry = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+	return math.max(math.ceil((tonumber(entry[2]) + window - now) / 1000), 1)
+end
+local resetAfter = 0
+if count > 0 then
+	resetAfter = expireAfter(-1)
+end
+if n <= 0 then
+	return {1, count, 0, resetAfter}
+end
+if count + n > max then
+	local retryAfter = -1
+	if n <= max then
+		retryAfter = expireAfter(count + n - max - 1)
+	end
+	return {0, count, retryAfter, resetAfter}
+end
+for i = 1, n do
+	redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(window / 1000))
+return {1, count + n, 0, math.ceil(window / 1000)}`)
+
+//counterScript 滑动窗口计数
+//KEYS[1]为哈希表,字段为窗口序号,值为窗口内的计数
+//当前水位为上一个窗口的计数按其在滑动窗口中所占的比例加权后加上当前窗口的计数
+//ARGV[1]为当前时间(ms),ARGV[2]为窗口时长(ms),ARGV[3]为最大数量,ARGV[4]为注入的数量
+//返回{是否成功,当前水位,需要等待的时间(ms,永远无法成功为-1),水位归零需要的时间(ms)}
+var counterScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local max = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local cur = math.floor(now / window)
+local curField = string.format("%d", cur)
+local prevField = string.format("%d", cur - 1)
+local counts = redis.call("HMGET", KEYS[1], curField, prevField)
+local curCount = tonumber(counts[1]) or 0
+local prevCount = tonumber(counts[2]) or 0
+local elapsed = now - cur * window
+local level = math.ceil(prevCount * (1 - elapsed / window) + curCount)
+local resetAfter = 0
+if curCount > 0 then
+	resetAfter = 2 * window - elapsed
+elseif prevCount > 0 then
+	resetAfter = window - elapsed
+end
+if n <= 0 then
+	return {1, level, 0, resetAfter}
+end
+if level + n > max then
+	local retryAfter = -1
+	if n <= max then
+		if curCount + n <= max then
+			retryAfter = (1 - (max - n - curCount) / prevCount) * window - elapsed
+		else
+			retryAfter = window - elapsed + (1 - (max - n) / curCount) * window
+		end
+		retryAfter = math.max(math.ceil(retryAfter), 1)
+	end
+	return {0, level, retryAfter, resetAfter}
+end
+redis.call("HINCRBY", KEYS[1], curField, n)
+for _, field in ipairs(redis.call("HKEYS", KEYS[1])) do
+	if field ~= curField and field ~= prevField then
+		redis.call("HDEL", KEYS[1], field)
+	end
+end
+redis.call("PEXPIRE", KEYS[1], window * 2)
+return {1, level + n, 0, 2 * window - elapsed}`)
+
+//Limiter 滑动窗口限制器
+type Limiter struct {
+	opt Options
+	*limiterhelper.LimiterABC
+	*middlewarehelper.MiddleWareAbc
+	script *redis.Script
+	args   func(maxsize, n int64) []any
+	prefix string
+	seq    int64
+}
+
+func newLimiter(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k := new(Limiter)
+	k.opt = defaultOptions
+	optparams.GetOption(&k.opt, opts...)
+	if k.opt.Window <= 0 {
+		return nil, ErrWindowMustLargerThanZero
+	}
+	l, err := limiterhelper.New(k.opt.LimiterOpts...)
+	if err != nil {
+		return nil, err
+	}
+	m, err := middlewarehelper.New(cli, "limiter", k.opt.MiddlewareOpts...)
+	if err != nil {
+		return nil, err
+	}
+	k.MiddleWareAbc = m
+	k.LimiterABC = l
+	return k, nil
+}
+
+//NewLog 创建一个滑动窗口日志限制器
+//每个计数在有序集合中保存为一个成员,结果精确但内存占用与窗口内的计数成正比,适合窗口内数量较小的场景
+//@params client redis.UniversalClient 客户端对象
+//@params opts ...optparams.Option[Options] limiter的可设置项
+func NewLog(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k, err := newLimiter(cli, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k.script = logScript
+	k.prefix = hex.EncodeToString(uuid.NewV4().Bytes())
+	k.args = func(maxsize, n int64) []any {
+		member := ""
+		if n > 0 {
+			member = k.prefix + ":" + strconv.FormatInt(atomic.AddInt64(&k.seq, 1), 10)
+		}
+		return []any{time.Now().UnixMicro(), k.opt.Window.Microseconds(), maxsize, n, member}
+	}
+	return k, nil
+}
+
+//NewCounter 创建一个滑动窗口计数限制器
+//只保存当前和上一个窗口的计数,假设上一个窗口内的请求均匀分布来估算滑动窗口内的数量,内存占用固定
+//@params client redis.UniversalClient 客户端对象
+//@params opts ...optparams.Option[Options] limiter的可设置项
+func NewCounter(cli redis.UniversalClient, opts ...optparams.Option[Options]) (*Limiter, error) {
+	k, err := newLimiter(cli, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k.script = counterScript
+	k.args = func(maxsize, n int64) []any {
+		return []any{time.Now().UnixMilli(), k.opt.Window.Milliseconds(), maxsize, n}
+	}
+	return k, nil
+}
+
+//Window 滑动窗口的时长
+func (c *Limiter) Window() time.Duration {
+	return c.opt.Window
+}
+
+//run 执行脚本并构造注水结果
+func (c *Limiter) run(ctx context.Context, key string, maxsize, value int64) (*limiterhelper.Reservation, error) {
+	res, err := c.script.Run(ctx, c.Client(), []string{key}, c.args(maxsize, value)...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != 4 {
+		return nil, ErrScriptResultNotMatch
+	}
+	retryAfter := time.Duration(res[2]) * time.Millisecond
+	if res[2] < 0 {
+		retryAfter = -1
+	}
+	return limiterhelper.NewReservation(res[0] == 1, maxsize, res[1], retryAfter, time.Duration(res[3])*time.Millisecond), nil
+}
+
+//Take 注水并返回详细结果,无法注入时不会占用窗口内的数量
+func (c *Limiter) Take(ctx context.Context, value int64) (*limiterhelper.Reservation, error) {
+	return c.run(ctx, c.Key(), c.Capacity(), value)
+}
+
+//Wait 阻塞直到注水成功或ctx结束
+//注水失败时等待足够多的计数滑出窗口后重试
+func (c *Limiter) Wait(ctx context.Context, value int64) error {
+	return limiterhelper.Wait(ctx, c.Take, value)
+}
+
+//Flood 灌注
+//当返回为true说明注水成功,false表示无法注入(满了),无法注入时不会占用窗口内的数量
+func (c *Limiter) Flood(ctx context.Context, value int64) (bool, error) {
+	res, err := c.Take(ctx, value)
+	if err != nil {
+		return true, err
+	}
+	c.LimiterABC.CheckWaterline(res.WaterLevel(), !res.Allowed)
+	return res.Allowed, nil
+}
+
+//TakeKey 对指定键注水并返回详细结果,窗口时长使用设置的Window
+//@params key string 滑动窗口使用的键
+//@params maxsize int64 窗口内的最大数量
+//@params value int64 注水量
+func (c *Limiter) TakeKey(ctx context.Context, key string, maxsize int64, value int64) (*limiterhelper.Reservation, error) {
+	return c.run(ctx, key, maxsize, value)
+}
+
+//FloodKey 对指定键注水,窗口时长使用设置的Window
+//当返回为true说明注水成功,false表示无法注入(满了),无法注入时不会占用窗口内的数量
+//@params key string 滑动窗口使用的键
+//@params maxsize int64 窗口内的最大数量
+//@params value int64 注水量
+func (c *Limiter) FloodKey(ctx context.Context, key string, maxsize int64, value int64) (bool, error) {
+	res, err := c.run(ctx, key, maxsize, value)
+	if err != nil {
+		return true, err
+	}
+	return res.Allowed, nil
+}
+
+//WaterLevel 当前水位,即滑动窗口内的数量
+func (c *Limiter) WaterLevel(ctx context.Context) (int64, error) {
+	res, err := c.run(ctx, c.Key(), c.Capacity(), 0)
+	if err != nil {
+		return 0, err
+	}
+	return res.WaterLevel(), nil
+}
+
+//IsFull 观测水位是否已满
+func (c *Limiter) IsFull(ctx context.Context) (bool, error) {
+	level, err := c.WaterLevel(ctx)
+	if err != nil {
+		return false, err
+	}
+	return level >= c.Capacity(), nil
+}
+
+//Reset 重置限制器
+func (c *Limiter) Reset(ctx context.Context) error {
+	_, err := c.Client().Del(ctx, c.Key()).Result()
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package streamhelper
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Golang-Tools/optparams"
@@ -14,6 +15,7 @@ import (
 //Consumer 流消费者对象
 type Consumer struct {
 	cli             redis.UniversalClient
+	listenLock      sync.Mutex //保护listenCtxCancel
 	listenCtxCancel context.CancelFunc
 	opt             Options
 	*clientIdhelper.ClientIDAbc
@@ -96,15 +98,29 @@ func (s *Consumer) Get(ctx context.Context, timeout time.Duration) ([]redis.XStr
 //@params topics string 监听的topic,复数topic用`,`隔开
 //@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
 func (s *Consumer) Listen(topics string, opts ...optparams.Option[pchelper.ListenOptions]) error {
+	return s.ListenWithContext(context.Background(), topics, opts...)
+}
+
+//ListenWithContext 监听流,ctx结束或调用StopListening时停止监听
+//与Listen不同,在监听开始之前ctx就已结束时也能保证监听会停止
+//@params ctx context.Context 监听的上下文
+//@params topics string 监听的topic,复数topic用`,`隔开
+//@params opts ...optparams.Option[pchelper.ListenOptions] 监听时的一些配置,具体看listenoption.go说明
+func (s *Consumer) ListenWithContext(ctx context.Context, topics string, opts ...optparams.Option[pchelper.ListenOptions]) error {
+	s.listenLock.Lock()
 	if s.listenCtxCancel != nil {
+		s.listenLock.Unlock()
 		return ErrStreamConsumerAlreadyListened
 	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.listenCtxCancel = cancel
+	s.listenLock.Unlock()
 	defer func() {
+		s.listenLock.Lock()
 		s.listenCtxCancel = nil
+		s.listenLock.Unlock()
+		cancel()
 	}()
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-	s.listenCtxCancel = cancel
 	listenopt := pchelper.DefaultListenOpt
 	optparams.GetOption(&listenopt, opts...)
 	topic_slice := strings.Split(topics, ",")
@@ -182,6 +198,8 @@ func (s *Consumer) Listen(topics string, opts ...optparams.Option[pchelper.Liste
 
 //StopListening 停止监听
 func (s *Consumer) StopListening() error {
+	s.listenLock.Lock()
+	defer s.listenLock.Unlock()
 	if s.listenCtxCancel == nil {
 		return ErrStreamConsumerNotListeningYet
 	}